@@ -1,12 +1,133 @@
 package config
 
 import (
+	"os"
+	"time"
+
 	"github.com/shopspring/decimal"
 	"github.com/sunneydev/pave-billing-api/bills/money"
+	"github.com/sunneydev/pave-billing-api/bills/tax"
 )
 
 var (
-	Rates             = &money.ExchangeRates{USDToGEL: decimal.NewFromFloat(2.7777), GELToUSD: decimal.NewFromFloat(0.3601)}
-	TemporalServerURL = "127.0.0.1:7233"
-	BillingTaskQueue  = "billing-task-queue"
+	Rates             money.RateProvider = defaultRates()
+	RatesFile                            = os.Getenv("BILLING_RATES_FILE")
+	TemporalServerURL                    = "127.0.0.1:7233"
+	BillingTaskQueue                     = "billing-task-queue"
+
+	// FXMarkups is the spread charged when a line item is converted to the bill currency.
+	FXMarkups           = money.Markups{}
+	FXMarkupModes       = map[int]money.MarkupMode{}
+	DefaultFXMarkupMode = money.MarkupSeparateFee
+
+	// CustomerLocales sets how amounts are formatted for a customer
+	// when the request does not ask for a locale.
+	CustomerLocales = map[int]money.Locale{}
+	DefaultLocale   = money.DefaultLocale
+
+	// CustomerTimezones sets the IANA timezone billing periods are aligned to.
+	CustomerTimezones = map[int]string{}
+	DefaultTimezone   = "UTC"
+
+	// TaxRates are charged on bills of customers in each jurisdiction, e.g. "GE" or "US-NY".
+	// Customers without a jurisdiction are not charged tax.
+	TaxRates              = tax.DefaultRates()
+	CustomerJurisdictions = map[int]string{}
+	DefaultJurisdiction   = ""
+	CustomerTaxExemptions = map[int]tax.Exemption{}
+
+	// BillRetention is how long a closed bill still accepts payments, credit notes
+	// and refunds. Bills still owed by then are written off as uncollectible.
+	BillRetention = 365 * day
+
+	// CustomerDunningSchedules sets when customers are reminded of unpaid bills.
+	// A zero schedule turns reminders off for the customer.
+	CustomerDunningSchedules = map[int]DunningSchedule{}
+	DefaultDunningSchedule   = DunningSchedule{
+		Reminders:   []time.Duration{3 * day, 7 * day, 14 * day},
+		FinalNotice: 21 * day,
+	}
 )
+
+const day = 24 * time.Hour
+
+// DunningSchedule lists how long after a bill is finalized each reminder is sent,
+// followed by the final notice if FinalNotice is set.
+type DunningSchedule struct {
+	Reminders   []time.Duration `json:"reminders"`
+	FinalNotice time.Duration   `json:"final_notice,omitempty"`
+}
+
+func CustomerDunningSchedule(customerID int) DunningSchedule {
+	if schedule, ok := CustomerDunningSchedules[customerID]; ok {
+		return schedule
+	}
+
+	return DefaultDunningSchedule
+}
+
+// CustomerTaxProfile returns the jurisdiction, rates and exemption used to tax a customer's bills.
+func CustomerTaxProfile(customerID int) tax.Profile {
+	profile := tax.Profile{Jurisdiction: DefaultJurisdiction}
+
+	if jurisdiction, ok := CustomerJurisdictions[customerID]; ok {
+		profile.Jurisdiction = jurisdiction
+	}
+
+	if profile.Jurisdiction == "" {
+		return profile
+	}
+
+	for _, rate := range TaxRates {
+		if rate.Jurisdiction == profile.Jurisdiction {
+			profile.Rates = append(profile.Rates, rate)
+		}
+	}
+
+	if exemption, ok := CustomerTaxExemptions[customerID]; ok {
+		profile.Exemption = &exemption
+	}
+
+	return profile
+}
+
+func CustomerTimezone(customerID int) string {
+	if timezone, ok := CustomerTimezones[customerID]; ok {
+		return timezone
+	}
+
+	return DefaultTimezone
+}
+
+func CustomerLocale(customerID int) money.Locale {
+	if locale, ok := CustomerLocales[customerID]; ok {
+		return locale
+	}
+
+	return DefaultLocale
+}
+
+// FXMarkupMode returns how conversion markups are shown to a customer.
+func FXMarkupMode(customerID int) money.MarkupMode {
+	if mode, ok := FXMarkupModes[customerID]; ok {
+		return mode
+	}
+
+	return DefaultFXMarkupMode
+}
+
+// defaultRates is used when no BILLING_RATES_FILE is configured.
+func defaultRates() *money.RateTable {
+	rates := money.NewRateTable(money.USD)
+
+	err := rates.Add(money.Quote{
+		Currency:    money.GEL,
+		Rate:        decimal.NewFromFloat(2.7777),
+		EffectiveAt: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return rates
+}
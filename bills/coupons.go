@@ -0,0 +1,278 @@
+package bill
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"encore.dev/rlog"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+
+	"github.com/sunneydev/pave-billing-api/bills/config"
+	"github.com/sunneydev/pave-billing-api/bills/errors"
+	"github.com/sunneydev/pave-billing-api/bills/workflow"
+)
+
+// CreateCoupon creates a coupon that takes a percentage or a fixed amount off
+// the bills it is redeemed against, until it expires. A coupon for a customer
+// is also applied to the bills they create, see applyCustomerCoupons.
+//
+//encore:api private method=POST path=/admin/coupons
+func (s *Service) CreateCoupon(ctx context.Context, params *CreateCouponParams) (coupon *workflow.Coupon, err error) {
+	created, err := params.Coupon(time.Now().UTC())
+	if err != nil {
+		return
+	}
+
+	options := client.StartWorkflowOptions{
+		ID:                                       couponWorkflowID(created.Code),
+		TaskQueue:                                config.BillingTaskQueue,
+		WorkflowExecutionErrorWhenAlreadyStarted: true,
+	}
+
+	// lets CreateBill find the coupons attached to the customer
+	if created.CustomerID != 0 {
+		options.SearchAttributes = map[string]interface{}{"CustomerID": created.CustomerID}
+	}
+
+	_, err = s.temporalClient.ExecuteWorkflow(
+		ctx,
+		options,
+		workflow.CouponWorkflow,
+		created,
+	)
+
+	if err != nil {
+		var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+		if stderrors.As(err, &alreadyStarted) {
+			err = errors.BadRequestError(fmt.Sprintf("coupon %s already exists", created.Code))
+		} else {
+			err = errors.SafeInternalError(err, "failed to start workflow")
+		}
+
+		return
+	}
+
+	return &created, nil
+}
+
+// GetCoupon retrieves a coupon and its redemptions.
+//
+//encore:api private method=GET path=/admin/coupons/:code
+func (s *Service) GetCoupon(ctx context.Context, code string) (coupon *workflow.Coupon, err error) {
+	resp, err := s.temporalClient.QueryWorkflow(ctx, couponWorkflowID(couponCode(code)), "", workflow.QueryGetCoupon)
+	if err != nil {
+		switch err.(type) {
+		case *serviceerror.NotFound:
+			err = errors.NotFoundError(err, "coupon")
+		default:
+			err = errors.SafeInternalError(err, "failed to query workflow")
+		}
+
+		return
+	}
+
+	if err = resp.Get(&coupon); err != nil {
+		err = errors.SafeInternalError(err, "failed to process coupon")
+	}
+
+	return
+}
+
+// RedeemCoupon applies a coupon to an open bill. The discount is added as
+// separate line items when the bill closes.
+//
+//encore:api public method=POST path=/bills/:billID/coupons
+func (s *Service) RedeemCoupon(ctx context.Context, billID string, params *RedeemCouponParams) (bill *workflow.Bill, err error) {
+	if err = params.Validate(); err != nil {
+		return
+	}
+
+	bill, err = s.getBill(ctx, billID, params.CustomerID)
+	if err != nil {
+		return
+	}
+
+	if !bill.Status.IsEditable() {
+		err = errors.BadRequestError("bill is closed")
+		return
+	}
+
+	if bill.HasCoupon(couponCode(params.Code)) {
+		err = errors.BadRequestError(fmt.Sprintf("coupon %s was already applied", couponCode(params.Code)))
+		return
+	}
+
+	if bill, err = s.applyCoupon(ctx, params.Code, bill); err != nil {
+		return
+	}
+
+	localize(bill, params.Locale, params.Accept)
+
+	return
+}
+
+// RedeemSubscriptionCoupon applies a coupon to the subscription's next bills,
+// as many as the coupon's periods, or all of them if it has none.
+//
+//encore:api public method=POST path=/subscriptions/:subscriptionID/coupons
+func (s *Service) RedeemSubscriptionCoupon(ctx context.Context, subscriptionID string, params *RedeemCouponParams) (subscription *workflow.Subscription, err error) {
+	if err = params.Validate(); err != nil {
+		return
+	}
+
+	subscription, err = s.getSubscription(ctx, subscriptionID, params.CustomerID)
+	if err != nil {
+		return
+	}
+
+	if subscription.Status == workflow.SubscriptionStatusCancelled {
+		err = errors.BadRequestError(fmt.Sprintf("subscription is %s", subscription.Status))
+		return
+	}
+
+	// replacing a discount would silently drop the periods left on it
+	if subscription.Discount != nil {
+		err = errors.BadRequestError(fmt.Sprintf("subscription already has coupon %s", subscription.Discount.CouponCode))
+		return
+	}
+
+	redemption := workflow.Redemption{CustomerID: params.CustomerID, SubscriptionID: subscriptionID}
+	discount, err := s.redeemCoupon(ctx, params.Code, workflow.RedeemCouponRequest{
+		Redemption: redemption,
+		Currency:   subscription.Currency,
+	})
+
+	if err != nil {
+		return
+	}
+
+	subscription, err = s.updateSubscription(ctx, subscriptionID, params.CustomerID, workflow.UpdateDiscountSubscription, *discount)
+	if err != nil {
+		s.releaseCoupon(ctx, params.Code, redemption)
+	}
+
+	return
+}
+
+// applyCoupon redeems the coupon for the bill and adds its discount, releasing
+// the redemption again if the bill rejects the discount.
+func (s *Service) applyCoupon(ctx context.Context, code string, bill *workflow.Bill) (*workflow.Bill, error) {
+	redemption := workflow.Redemption{CustomerID: bill.CustomerID, BillID: bill.ID}
+	discount, err := s.redeemCoupon(ctx, code, workflow.RedeemCouponRequest{
+		Redemption: redemption,
+		Currency:   bill.Currency,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := s.updateBill(ctx, bill.ID, workflow.UpdateApplyDiscount, *discount)
+	if err != nil {
+		s.releaseCoupon(ctx, code, redemption)
+		return nil, err
+	}
+
+	return updated, nil
+}
+
+// applyCustomerCoupons applies the running coupons attached to the customer to
+// their new bill. Coupons that cannot be applied, e.g. because they were used
+// for all their periods or are in another currency, are skipped.
+func (s *Service) applyCustomerCoupons(ctx context.Context, bill *workflow.Bill) *workflow.Bill {
+	query := fmt.Sprintf("WorkflowType = 'CouponWorkflow' AND CustomerID = %d AND ExecutionStatus = 'Running'", bill.CustomerID)
+	resp, err := s.temporalClient.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+		Query: query,
+	})
+
+	if err != nil {
+		rlog.Error("failed to list customer coupons",
+			"customer_id", bill.CustomerID,
+			"error", err,
+		)
+
+		return bill
+	}
+
+	for _, execution := range resp.Executions {
+		code := strings.TrimPrefix(execution.Execution.WorkflowId, couponWorkflowID(""))
+
+		discounted, err := s.applyCoupon(ctx, code, bill)
+		if err != nil {
+			rlog.Info("customer coupon not applied",
+				"code", code,
+				"bill_id", bill.ID,
+				"error", err,
+			)
+
+			continue
+		}
+
+		bill = discounted
+	}
+
+	return bill
+}
+
+// redeemCoupon records the redemption on the coupon, which rejects it if the
+// coupon has expired or cannot be used by the customer or in the currency.
+func (s *Service) redeemCoupon(ctx context.Context, code string, request workflow.RedeemCouponRequest) (discount *workflow.Discount, err error) {
+	handle, err := s.temporalClient.UpdateWorkflow(ctx, client.UpdateWorkflowOptions{
+		WorkflowID:   couponWorkflowID(couponCode(code)),
+		UpdateName:   workflow.UpdateRedeemCoupon,
+		Args:         []interface{}{request},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	})
+
+	if err == nil {
+		err = handle.Get(ctx, &discount)
+	}
+
+	if err != nil {
+		var appErr *temporal.ApplicationError
+		var notFound *serviceerror.NotFound
+		switch {
+		case stderrors.As(err, &appErr):
+			err = errors.BadRequestError(appErr.Message())
+		case stderrors.As(err, &notFound):
+			err = errors.NotFoundError(err, "coupon")
+		default:
+			err = errors.SafeInternalError(err, "failed to redeem coupon")
+		}
+	}
+
+	return
+}
+
+// releaseCoupon removes a redemption whose discount could not be applied, so the
+// coupon is not recorded as used. It runs even if the request was cancelled.
+func (s *Service) releaseCoupon(ctx context.Context, code string, redemption workflow.Redemption) {
+	handle, err := s.temporalClient.UpdateWorkflow(context.WithoutCancel(ctx), client.UpdateWorkflowOptions{
+		WorkflowID:   couponWorkflowID(couponCode(code)),
+		UpdateName:   workflow.UpdateReleaseCoupon,
+		Args:         []interface{}{redemption},
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	})
+
+	if err == nil {
+		err = handle.Get(context.WithoutCancel(ctx), nil)
+	}
+
+	if err != nil {
+		rlog.Error("failed to release coupon",
+			"code", couponCode(code),
+			"customer_id", redemption.CustomerID,
+			"error", err,
+		)
+	}
+}
+
+func couponWorkflowID(code string) string {
+	return "coupon-" + code
+}
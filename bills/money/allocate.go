@@ -0,0 +1,82 @@
+package money
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+// Allocate splits m into parts proportional to ratios using the largest-remainder
+// method, so the parts always sum exactly to m. Leftover minor units go to the
+// parts with the largest remainders, ties going to the earlier part.
+func (m Money) Allocate(ratios ...int) ([]Money, error) {
+	if len(ratios) == 0 {
+		return nil, fmt.Errorf("no ratios provided")
+	}
+
+	total := big.NewInt(0)
+	for _, ratio := range ratios {
+		if ratio < 0 {
+			return nil, fmt.Errorf("ratio cannot be negative: %d", ratio)
+		}
+
+		total.Add(total, big.NewInt(int64(ratio)))
+	}
+
+	if total.Sign() == 0 {
+		return nil, fmt.Errorf("sum of ratios must be positive")
+	}
+
+	abs := new(big.Int).Abs(big.NewInt(m.minor))
+
+	parts := make([]Money, len(ratios))
+	remainders := make([]*big.Int, len(ratios))
+	allocated := int64(0)
+
+	for i, ratio := range ratios {
+		share, remainder := new(big.Int).QuoRem(
+			new(big.Int).Mul(abs, big.NewInt(int64(ratio))),
+			total,
+			new(big.Int),
+		)
+
+		parts[i] = Money{minor: share.Int64(), Currency: m.Currency}
+		remainders[i] = remainder
+		allocated += share.Int64()
+	}
+
+	order := make([]int, len(ratios))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]].Cmp(remainders[order[b]]) > 0
+	})
+
+	for i := int64(0); i < abs.Int64()-allocated; i++ {
+		parts[order[i]].minor++
+	}
+
+	if m.minor < 0 {
+		for i := range parts {
+			parts[i] = parts[i].Neg()
+		}
+	}
+
+	return parts, nil
+}
+
+// Split divides m into n parts that differ by at most one minor unit.
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("cannot split into %d parts", n)
+	}
+
+	ratios := make([]int, n)
+	for i := range ratios {
+		ratios[i] = 1
+	}
+
+	return m.Allocate(ratios...)
+}
@@ -0,0 +1,92 @@
+package money
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sumMinor(parts []Money) int64 {
+	var total int64
+	for _, p := range parts {
+		total += p.minor
+	}
+
+	return total
+}
+
+func Test_Money_Allocate_DistributesRemainderByLargestRemainder(t *testing.T) {
+	m := New(decimal.NewFromFloat(100), USD)
+
+	parts, err := m.Allocate(1, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []int64{3334, 3333, 3333}, []int64{parts[0].minor, parts[1].minor, parts[2].minor})
+
+	parts, err = New(decimal.NewFromFloat(0.05), USD).Allocate(30, 70)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), parts[0].minor)
+	assert.Equal(t, int64(3), parts[1].minor)
+}
+
+func Test_Money_Allocate_SumsToOriginal(t *testing.T) {
+	amounts := []int64{0, 1, 7, 100, 99999, 123456789}
+	ratios := [][]int{{1}, {1, 2}, {3, 3, 3}, {70, 20, 10}, {0, 5, 1}, {333, 333, 334}}
+
+	for _, amount := range amounts {
+		for _, r := range ratios {
+			m := Money{minor: amount, Currency: GEL}
+
+			parts, err := m.Allocate(r...)
+			require.NoError(t, err)
+			require.Len(t, parts, len(r))
+			assert.Equal(t, amount, sumMinor(parts), "amount %d ratios %v", amount, r)
+
+			for _, p := range parts {
+				assert.Equal(t, GEL, p.Currency)
+			}
+		}
+	}
+}
+
+func Test_Money_Allocate_HandlesNegativeAmounts(t *testing.T) {
+	m := Money{minor: -1000, Currency: USD}
+
+	parts, err := m.Allocate(1, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-333), parts[0].minor)
+	assert.Equal(t, int64(-667), parts[1].minor)
+}
+
+func Test_Money_Allocate_SkipsZeroRatios(t *testing.T) {
+	parts, err := Money{minor: 101, Currency: USD}.Allocate(0, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), parts[0].minor)
+	assert.Equal(t, int64(51), parts[1].minor)
+	assert.Equal(t, int64(50), parts[2].minor)
+}
+
+func Test_Money_Allocate_RejectsInvalidRatios(t *testing.T) {
+	m := New(decimal.NewFromFloat(10), USD)
+
+	_, err := m.Allocate()
+	assert.Error(t, err)
+
+	_, err = m.Allocate(0, 0)
+	assert.Error(t, err)
+
+	_, err = m.Allocate(1, -1)
+	assert.Error(t, err)
+}
+
+func Test_Money_Split_DividesEvenly(t *testing.T) {
+	parts, err := Money{minor: 1000, Currency: JPY}.Split(3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(334), parts[0].minor)
+	assert.Equal(t, int64(333), parts[1].minor)
+	assert.Equal(t, int64(333), parts[2].minor)
+
+	_, err = Money{minor: 1000, Currency: JPY}.Split(0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,148 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/shopspring/decimal"
+)
+
+var hundred = decimal.NewFromInt(100)
+
+// ErrOverflow is returned when a result does not fit in int64 minor units.
+// The valid range is symmetric, [-MaxInt64, MaxInt64], so Neg and Abs never overflow.
+var ErrOverflow = errors.New("amount overflows supported range")
+
+func addMinor(a, b int64) (int64, error) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) || sum == math.MinInt64 {
+		return 0, ErrOverflow
+	}
+
+	return sum, nil
+}
+
+func mulMinor(a, b int64) (int64, error) {
+	if a == 0 || b == 0 {
+		return 0, nil
+	}
+
+	product := a * b
+	if product/b != a || product == math.MinInt64 {
+		return 0, ErrOverflow
+	}
+
+	return product, nil
+}
+
+func (m Money) sameCurrency(other Money, op string) error {
+	if m.Currency != other.Currency {
+		return fmt.Errorf("cannot %s different currencies: %s and %s", op, m.Currency, other.Currency)
+	}
+
+	return nil
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if err := m.sameCurrency(other, "add"); err != nil {
+		return Money{}, err
+	}
+
+	minor, err := addMinor(m.minor, other.minor)
+	if err != nil {
+		return Money{}, err
+	}
+
+	return Money{minor: minor, Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	if err := m.sameCurrency(other, "subtract"); err != nil {
+		return Money{}, err
+	}
+
+	minor, err := addMinor(m.minor, -other.minor)
+	if err != nil {
+		return Money{}, err
+	}
+
+	return Money{minor: minor, Currency: m.Currency}, nil
+}
+
+func (m Money) Neg() Money {
+	return Money{minor: -m.minor, Currency: m.Currency}
+}
+
+func (m Money) Abs() Money {
+	if m.minor < 0 {
+		return m.Neg()
+	}
+
+	return m
+}
+
+// Cmp returns -1, 0 or +1 depending on whether m is less than, equal to or greater than other.
+func (m Money) Cmp(other Money) (int, error) {
+	if err := m.sameCurrency(other, "compare"); err != nil {
+		return 0, err
+	}
+
+	switch {
+	case m.minor < other.minor:
+		return -1, nil
+	case m.minor > other.minor:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// Equal reports whether both the currency and the amount match.
+func (m Money) Equal(other Money) bool {
+	return m.Currency == other.Currency && m.minor == other.minor
+}
+
+func (m Money) IsZero() bool {
+	return m.minor == 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.minor < 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.minor > 0
+}
+
+// Mul multiplies the amount by a decimal factor, rounding to the currency's minor units.
+func (m Money) Mul(factor decimal.Decimal, opts ...Option) (Money, error) {
+	return fromDecimal(m.Amount().Mul(factor), m.Currency, opts...)
+}
+
+func (m Money) MulInt(quantity int64) (Money, error) {
+	minor, err := mulMinor(m.minor, quantity)
+	if err != nil {
+		return Money{}, err
+	}
+
+	return Money{minor: minor, Currency: m.Currency}, nil
+}
+
+// Percent returns pct percent of m, e.g. Percent(18) for an 18% VAT amount.
+func (m Money) Percent(pct decimal.Decimal, opts ...Option) (Money, error) {
+	return m.Mul(pct.Div(hundred), opts...)
+}
+
+// PercentOf returns the percentage that m represents of total.
+func (m Money) PercentOf(total Money) (decimal.Decimal, error) {
+	if err := m.sameCurrency(total, "compare"); err != nil {
+		return decimal.Decimal{}, err
+	}
+
+	if total.IsZero() {
+		return decimal.Decimal{}, fmt.Errorf("cannot compute percentage of zero amount")
+	}
+
+	return decimal.NewFromInt(m.minor).Mul(hundred).Div(decimal.NewFromInt(total.minor)), nil
+}
@@ -0,0 +1,124 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewSignedFromString_AcceptsNegativeAmounts(t *testing.T) {
+	m, err := NewSignedFromString("-12.34", USD)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1234), m.minor)
+	assert.True(t, m.IsNegative())
+
+	_, err = NewSignedFromString("-12.34", "INVALID")
+	assert.Error(t, err)
+}
+
+func Test_Money_Sub_AllowsNegativeResults(t *testing.T) {
+	m1 := New(decimal.NewFromFloat(10), USD)
+	m2 := New(decimal.NewFromFloat(25.5), USD)
+
+	result, err := m1.Sub(m2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1550), result.minor)
+	assert.Equal(t, "-$15.50", result.String())
+}
+
+func Test_Money_Sub_RejectsDifferentCurrencies(t *testing.T) {
+	_, err := New(decimal.NewFromFloat(10), USD).Sub(New(decimal.NewFromFloat(1), GEL))
+	assert.Error(t, err)
+}
+
+func Test_Money_NegAndAbs(t *testing.T) {
+	m := New(decimal.NewFromFloat(10), GEL)
+
+	assert.Equal(t, int64(-1000), m.Neg().minor)
+	assert.Equal(t, GEL, m.Neg().Currency)
+	assert.Equal(t, m, m.Neg().Abs())
+	assert.Equal(t, m, m.Abs())
+}
+
+func Test_Money_Cmp_ComparesSameCurrency(t *testing.T) {
+	small := New(decimal.NewFromFloat(1), USD)
+	large := New(decimal.NewFromFloat(2), USD)
+
+	cmp, err := small.Cmp(large)
+	require.NoError(t, err)
+	assert.Equal(t, -1, cmp)
+
+	cmp, err = large.Cmp(small)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cmp)
+
+	cmp, err = small.Cmp(small)
+	require.NoError(t, err)
+	assert.Equal(t, 0, cmp)
+
+	_, err = small.Cmp(New(decimal.NewFromFloat(1), GEL))
+	assert.Error(t, err)
+}
+
+func Test_Money_Equal_ChecksCurrencyAndAmount(t *testing.T) {
+	m := New(decimal.NewFromFloat(1), USD)
+
+	assert.True(t, m.Equal(New(decimal.NewFromFloat(1), USD)))
+	assert.False(t, m.Equal(New(decimal.NewFromFloat(1), GEL)))
+	assert.False(t, m.Equal(New(decimal.NewFromFloat(2), USD)))
+}
+
+func Test_Money_IsZero(t *testing.T) {
+	assert.True(t, New(ZeroAmount(), USD).IsZero())
+	assert.False(t, New(decimal.NewFromFloat(0.01), USD).IsZero())
+}
+
+func Test_Money_Mul_RoundsToMinorUnits(t *testing.T) {
+	m := New(decimal.NewFromFloat(10.01), USD)
+
+	result, err := m.Mul(decimal.NewFromFloat(1.5))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1502), result.minor)
+
+	result, err = m.Mul(decimal.NewFromInt(-1))
+	require.NoError(t, err)
+	assert.Equal(t, int64(-1001), result.minor)
+
+	result, err = m.MulInt(3)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3003), result.minor)
+}
+
+func Test_Money_Percent(t *testing.T) {
+	m := New(decimal.NewFromFloat(100.50), GEL)
+
+	vat, err := m.Percent(decimal.NewFromInt(18))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1809), vat.minor)
+	assert.Equal(t, GEL, vat.Currency)
+
+	pct, err := vat.PercentOf(m)
+	require.NoError(t, err)
+	assert.True(t, pct.Round(2).Equal(decimal.NewFromFloat(18)))
+
+	_, err = vat.PercentOf(New(ZeroAmount(), GEL))
+	assert.Error(t, err)
+
+	_, err = vat.PercentOf(New(decimal.NewFromFloat(100.50), USD))
+	assert.Error(t, err)
+}
+
+func Test_Money_JSON_RoundTripsNegativeAmounts(t *testing.T) {
+	m := New(decimal.NewFromFloat(-42.5), USD)
+
+	data, err := json.Marshal(m)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"-42.50","currency":"USD","minor_units":-4250}`, string(data))
+
+	var decoded Money
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, m, decoded)
+}
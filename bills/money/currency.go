@@ -0,0 +1,161 @@
+package money
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Currency string
+
+const (
+	USD Currency = "USD"
+	GEL Currency = "GEL"
+	EUR Currency = "EUR"
+	GBP Currency = "GBP"
+	JPY Currency = "JPY"
+	KWD Currency = "KWD"
+)
+
+// maxMinorUnits bounds the exponent so amounts still fit comfortably in int64.
+const maxMinorUnits = 6
+
+// CurrencyInfo describes a currency as defined by ISO 4217.
+// MinorUnits is the exponent between the major and minor unit,
+// e.g. 2 for USD (cents), 0 for JPY and 3 for KWD (fils).
+// Rounding is the default used when amounts are rounded to minor units.
+type CurrencyInfo struct {
+	Code       Currency     `json:"code"`
+	Symbol     string       `json:"symbol"`
+	MinorUnits int32        `json:"minor_units"`
+	Name       string       `json:"name"`
+	Rounding   RoundingMode `json:"rounding,omitempty"`
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[Currency]CurrencyInfo{}
+)
+
+func init() {
+	for _, info := range []CurrencyInfo{
+		{Code: USD, Symbol: "$", MinorUnits: 2, Name: "US Dollar"},
+		{Code: GEL, Symbol: "₾", MinorUnits: 2, Name: "Georgian Lari"},
+		{Code: EUR, Symbol: "€", MinorUnits: 2, Name: "Euro"},
+		{Code: GBP, Symbol: "£", MinorUnits: 2, Name: "Pound Sterling"},
+		{Code: JPY, Symbol: "¥", MinorUnits: 0, Name: "Yen"},
+		{Code: KWD, Symbol: "KD", MinorUnits: 3, Name: "Kuwaiti Dinar"},
+	} {
+		if err := Register(info); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Register adds a currency to the registry or replaces an existing entry.
+func Register(info CurrencyInfo) error {
+	if len(info.Code) != 3 || strings.ToUpper(string(info.Code)) != string(info.Code) {
+		return fmt.Errorf("invalid currency code: %q", info.Code)
+	}
+
+	if info.MinorUnits < 0 || info.MinorUnits > maxMinorUnits {
+		return fmt.Errorf("invalid minor units for %s: %d", info.Code, info.MinorUnits)
+	}
+
+	if info.Symbol == "" {
+		info.Symbol = string(info.Code)
+	}
+
+	if info.Rounding == "" {
+		info.Rounding = DefaultRoundingMode
+	}
+
+	if err := info.Rounding.Validate(); err != nil {
+		return err
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry[info.Code] = info
+
+	return nil
+}
+
+// Lookup returns the registered details of a currency.
+func Lookup(c Currency) (info CurrencyInfo, ok bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	info, ok = registry[c]
+	return
+}
+
+// Currencies returns all registered currencies ordered by code.
+func Currencies() []CurrencyInfo {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	infos := make([]CurrencyInfo, 0, len(registry))
+	for _, info := range registry {
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Code < infos[j].Code })
+
+	return infos
+}
+
+func (c Currency) IsValid() bool {
+	_, ok := Lookup(c)
+	return ok
+}
+
+func (c Currency) Symbol() string {
+	if info, ok := Lookup(c); ok {
+		return info.Symbol
+	}
+
+	return string(c)
+}
+
+func (c Currency) Name() string {
+	if info, ok := Lookup(c); ok {
+		return info.Name
+	}
+
+	return string(c)
+}
+
+// MinorUnits returns the currency exponent, defaulting to 2 for unknown currencies.
+func (c Currency) MinorUnits() int32 {
+	if info, ok := Lookup(c); ok {
+		return info.MinorUnits
+	}
+
+	return 2
+}
+
+func (c Currency) Rounding() RoundingMode {
+	if info, ok := Lookup(c); ok {
+		return info.Rounding
+	}
+
+	return DefaultRoundingMode
+}
+
+// currencyFromSymbolPrefix finds the registered currency whose symbol prefixes s,
+// preferring the longest symbol so that e.g. "KD" is not shadowed by a shorter one.
+func currencyFromSymbolPrefix(s string) (currency Currency, rest string, ok bool) {
+	longest := 0
+
+	for _, info := range Currencies() {
+		if len(info.Symbol) > longest && strings.HasPrefix(s, info.Symbol) {
+			currency, longest, ok = info.Code, len(info.Symbol), true
+		}
+	}
+
+	rest = s[longest:]
+	return
+}
@@ -0,0 +1,84 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Lookup_ReturnsBuiltinCurrencies(t *testing.T) {
+	tests := []struct {
+		currency   Currency
+		symbol     string
+		minorUnits int32
+	}{
+		{USD, "$", 2},
+		{GEL, "₾", 2},
+		{EUR, "€", 2},
+		{GBP, "£", 2},
+		{JPY, "¥", 0},
+		{KWD, "KD", 3},
+	}
+
+	for _, tt := range tests {
+		info, ok := Lookup(tt.currency)
+		require.True(t, ok, tt.currency)
+		assert.Equal(t, tt.symbol, info.Symbol)
+		assert.Equal(t, tt.minorUnits, info.MinorUnits)
+		assert.NotEmpty(t, info.Name)
+	}
+
+	_, ok := Lookup("XXX")
+	assert.False(t, ok)
+}
+
+func Test_Register_RejectsInvalidCurrencies(t *testing.T) {
+	assert.Error(t, Register(CurrencyInfo{Code: "usd", MinorUnits: 2}))
+	assert.Error(t, Register(CurrencyInfo{Code: "USDT", MinorUnits: 2}))
+	assert.Error(t, Register(CurrencyInfo{Code: "XTS", MinorUnits: -1}))
+	assert.Error(t, Register(CurrencyInfo{Code: "XTS", MinorUnits: 7}))
+	assert.False(t, Currency("XTS").IsValid())
+}
+
+func Test_Register_AddsCustomCurrency(t *testing.T) {
+	require.NoError(t, Register(CurrencyInfo{Code: "XTS", MinorUnits: 4, Name: "Testing"}))
+	defer func() {
+		registryMu.Lock()
+		delete(registry, "XTS")
+		registryMu.Unlock()
+	}()
+
+	m, err := NewFromString("1.23456", "XTS")
+	require.NoError(t, err)
+	assert.Equal(t, int64(12346), m.minor)
+	assert.Equal(t, "XTS1.2346", m.String())
+}
+
+func Test_New_UsesCurrencyMinorUnits(t *testing.T) {
+	m := New(decimal.NewFromFloat(1234.5), JPY)
+	assert.Equal(t, int64(1235), m.minor)
+	assert.Equal(t, "¥1235", m.String())
+
+	m = New(decimal.NewFromFloat(1.2345), KWD)
+	assert.Equal(t, int64(1235), m.minor)
+	assert.Equal(t, "KD1.235", m.String())
+	assert.True(t, decimal.RequireFromString("1.235").Equal(m.Amount()))
+}
+
+func Test_Money_UnmarshalJSON_ParsesRegisteredSymbols(t *testing.T) {
+	var m Money
+	require.NoError(t, json.Unmarshal([]byte(`"€123.45"`), &m))
+	assert.Equal(t, EUR, m.Currency)
+	assert.Equal(t, int64(12345), m.minor)
+
+	require.NoError(t, json.Unmarshal([]byte(`"KD 1.5"`), &m))
+	assert.Equal(t, KWD, m.Currency)
+	assert.Equal(t, int64(1500), m.minor)
+
+	require.NoError(t, json.Unmarshal([]byte(`"¥500"`), &m))
+	assert.Equal(t, JPY, m.Currency)
+	assert.Equal(t, int64(500), m.minor)
+}
@@ -0,0 +1,321 @@
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// JSONFormat selects how API responses encode Money. It is negotiated per
+// request, see NegotiateJSONFormat. MarshalJSON always uses the object form,
+// so workflow payloads are the same whatever the client asked for, and
+// ConvertJSON rewrites a response into the negotiated format. Decoding accepts
+// every format, so values written by an older version can always be read back.
+type JSONFormat string
+
+const (
+	// JSONFormatObject encodes {"amount":"10.00","currency":"USD","minor_units":1000}.
+	JSONFormatObject JSONFormat = "object"
+	// JSONFormatString is the legacy "$10.00" encoding.
+	JSONFormatString JSONFormat = "string"
+)
+
+func (f JSONFormat) Validate() error {
+	switch f {
+	case JSONFormatObject, JSONFormatString:
+		return nil
+	default:
+		return fmt.Errorf("invalid money JSON format: %q", f)
+	}
+}
+
+// NegotiateJSONFormat reads the format from the money parameter of an Accept
+// header, e.g. "application/json; money=string". Media ranges without a valid
+// money parameter, and requests without one, get the object form.
+func NegotiateJSONFormat(accept string) JSONFormat {
+	for _, mediaRange := range strings.Split(accept, ",") {
+		_, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+
+		if f := JSONFormat(strings.ToLower(params["money"])); f.Validate() == nil {
+			return f
+		}
+	}
+
+	return JSONFormatObject
+}
+
+// ConvertJSON rewrites the amounts in data, as encoded by MarshalJSON, into format f.
+// Everything else, including the order of the keys, is left as it is.
+func ConvertJSON(data []byte, f JSONFormat) ([]byte, error) {
+	if f == JSONFormatObject {
+		return data, nil
+	}
+
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := convertJSON(&out, data); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// convertJSON writes data to out with every Money object replaced by its legacy string.
+func convertJSON(out *bytes.Buffer, data json.RawMessage) error {
+	data = bytes.TrimSpace(data)
+
+	switch {
+	case bytes.HasPrefix(data, []byte("[")):
+		var values []json.RawMessage
+		if err := json.Unmarshal(data, &values); err != nil {
+			return err
+		}
+
+		out.WriteByte('[')
+		for i, value := range values {
+			if i > 0 {
+				out.WriteByte(',')
+			}
+
+			if err := convertJSON(out, value); err != nil {
+				return err
+			}
+		}
+		out.WriteByte(']')
+
+		return nil
+	case !bytes.HasPrefix(data, []byte("{")):
+		out.Write(data)
+		return nil
+	}
+
+	keys, values, err := jsonMembers(data)
+	if err != nil {
+		return err
+	}
+
+	if isMoneyObject(keys) {
+		// not validated, zero values without a currency are written as they always were
+		var object moneyObject
+		if err := json.Unmarshal(data, &object); err != nil {
+			return err
+		}
+
+		m := Money{minor: object.MinorUnits, Currency: object.Currency}
+		encoded, err := json.Marshal(m.FormatWithSymbol())
+		if err != nil {
+			return err
+		}
+
+		out.Write(encoded)
+
+		return nil
+	}
+
+	out.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+
+		encoded, err := json.Marshal(key)
+		if err != nil {
+			return err
+		}
+
+		out.Write(encoded)
+		out.WriteByte(':')
+
+		if err := convertJSON(out, values[i]); err != nil {
+			return err
+		}
+	}
+	out.WriteByte('}')
+
+	return nil
+}
+
+// jsonMembers returns the keys and values of a JSON object in the order they appear.
+func jsonMembers(data []byte) (keys []string, values []json.RawMessage, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if _, err = decoder.Token(); err != nil {
+		return
+	}
+
+	for decoder.More() {
+		var key json.Token
+		if key, err = decoder.Token(); err != nil {
+			return
+		}
+
+		var value json.RawMessage
+		if err = decoder.Decode(&value); err != nil {
+			return
+		}
+
+		keys = append(keys, key.(string))
+		values = append(values, value)
+	}
+
+	return
+}
+
+// isMoneyObject reports whether an object has exactly the keys MarshalJSON writes.
+func isMoneyObject(keys []string) bool {
+	if len(keys) != 3 {
+		return false
+	}
+
+	for _, key := range keys {
+		if key != "amount" && key != "currency" && key != "minor_units" {
+			return false
+		}
+	}
+
+	return true
+}
+
+type moneyObject struct {
+	Amount     string   `json:"amount"`
+	Currency   Currency `json:"currency"`
+	MinorUnits int64    `json:"minor_units"`
+}
+
+type moneyObjectInput struct {
+	Amount     *string  `json:"amount"`
+	Currency   Currency `json:"currency"`
+	MinorUnits *int64   `json:"minor_units"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyObject{
+		Amount:     minorToDecimalString(m.minor, m.Currency.MinorUnits()),
+		Currency:   m.Currency,
+		MinorUnits: m.minor,
+	})
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		return nil
+	case bytes.HasPrefix(data, []byte("{")):
+		return m.unmarshalObject(data)
+	default:
+		return m.unmarshalString(data)
+	}
+}
+
+// unmarshalObject requires amount, minor_units or both. When both are given they
+// must describe the same value, and amount may not be more precise than the currency.
+func (m *Money) unmarshalObject(data []byte) error {
+	var input moneyObjectInput
+	if err := json.Unmarshal(data, &input); err != nil {
+		return err
+	}
+
+	if !input.Currency.IsValid() {
+		return fmt.Errorf("invalid currency: %s", input.Currency)
+	}
+
+	if input.Amount == nil && input.MinorUnits == nil {
+		return fmt.Errorf("invalid amount: amount or minor_units is required")
+	}
+
+	result := Money{Currency: input.Currency}
+
+	if input.MinorUnits != nil {
+		// outside the symmetric range, so it could not be negated
+		if *input.MinorUnits == math.MinInt64 {
+			return fmt.Errorf("invalid amount: %w", ErrOverflow)
+		}
+
+		result.minor = *input.MinorUnits
+	}
+
+	if input.Amount != nil {
+		amount, err := decimal.NewFromString(strings.TrimSpace(*input.Amount))
+		if err != nil {
+			return fmt.Errorf("invalid amount: %v", err)
+		}
+
+		exp := input.Currency.MinorUnits()
+
+		minor, err := decimalToMinor(amount, exp, RoundTowardZero)
+		if err != nil {
+			return fmt.Errorf("invalid amount: %w", err)
+		}
+
+		if !minorToDecimal(minor, exp).Equal(amount) {
+			return fmt.Errorf("invalid amount: %s has more than %d decimal places", amount, exp)
+		}
+
+		if input.MinorUnits != nil && minor != *input.MinorUnits {
+			return fmt.Errorf("invalid amount: %s does not match minor_units %d", amount, *input.MinorUnits)
+		}
+
+		result.minor = minor
+	}
+
+	if err := result.validateSigned(); err != nil {
+		return err
+	}
+
+	*m = result
+
+	return nil
+}
+
+func (m *Money) unmarshalString(data []byte) error {
+	var valueStr string
+	if err := json.Unmarshal(data, &valueStr); err != nil {
+		return err
+	}
+
+	valueStr = strings.TrimSpace(valueStr)
+
+	negative := strings.HasPrefix(valueStr, "-")
+	if negative {
+		valueStr = strings.TrimPrefix(valueStr, "-")
+	}
+
+	currency, rest, ok := currencyFromSymbolPrefix(valueStr)
+	if !ok {
+		return fmt.Errorf("invalid amount: missing currency symbol in %q", valueStr)
+	}
+
+	amount, err := decimal.NewFromString(strings.TrimSpace(rest))
+	if err != nil {
+		return fmt.Errorf("invalid amount: %v", err)
+	}
+
+	if negative {
+		amount = amount.Neg()
+	}
+
+	result := Money{Currency: currency}
+	if result.minor, err = decimalToMinor(amount, currency.MinorUnits(), currency.Rounding()); err != nil {
+		return fmt.Errorf("invalid amount: %w", err)
+	}
+
+	if err := result.validateSigned(); err != nil {
+		return err
+	}
+
+	*m = result
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// marshalJSON encodes v as a response in format f.
+func marshalJSON(t *testing.T, v interface{}, f JSONFormat) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+
+	data, err = ConvertJSON(data, f)
+	require.NoError(t, err)
+
+	return data
+}
+
+func Test_Money_MarshalJSON_DefaultsToObject(t *testing.T) {
+	data, err := json.Marshal(Money{minor: 1000, Currency: USD})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"10.00","currency":"USD","minor_units":1000}`, string(data))
+
+	data, err = json.Marshal(Money{minor: 1500, Currency: KWD})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"1.500","currency":"KWD","minor_units":1500}`, string(data))
+}
+
+func Test_NegotiateJSONFormat(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   JSONFormat
+	}{
+		{"", JSONFormatObject},
+		{"application/json", JSONFormatObject},
+		{"application/json; money=string", JSONFormatString},
+		{"application/json;money=STRING", JSONFormatString},
+		{"text/html, application/json; money=object", JSONFormatObject},
+		{"text/html, application/json; money=string", JSONFormatString},
+		{"application/json; money=xml", JSONFormatObject},
+		{"application/json; money=", JSONFormatObject},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, NegotiateJSONFormat(tt.accept), tt.accept)
+	}
+}
+
+func Test_ConvertJSON_RewritesAmountsOnly(t *testing.T) {
+	type lineItem struct {
+		ID     string `json:"id"`
+		Amount Money  `json:"amount"`
+	}
+
+	type bill struct {
+		Total     Money      `json:"total"`
+		Subtotal  Precise    `json:"subtotal"`
+		Currency  Currency   `json:"currency"`
+		LineItems []lineItem `json:"line_items"`
+		Markup    *Money     `json:"markup"`
+	}
+
+	subtotal, err := NewPreciseFromString("10.005", USD)
+	require.NoError(t, err)
+
+	value := bill{
+		Total:     Money{minor: 1000, Currency: USD},
+		Subtotal:  subtotal,
+		Currency:  USD,
+		LineItems: []lineItem{{ID: "a", Amount: Money{minor: -250, Currency: GEL}}},
+	}
+
+	assert.Equal(t,
+		`{"total":"$10.00","subtotal":{"amount":"10.005","currency":"USD"},"currency":"USD","line_items":[{"id":"a","amount":"-₾2.50"}],"markup":null}`,
+		string(marshalJSON(t, value, JSONFormatString)))
+
+	data, err := json.Marshal(value)
+	require.NoError(t, err)
+	assert.Equal(t, string(data), string(marshalJSON(t, value, JSONFormatObject)))
+
+	_, err = ConvertJSON(data, "xml")
+	assert.Error(t, err)
+}
+
+func Test_Money_UnmarshalJSON_ParsesObject(t *testing.T) {
+	tests := []struct {
+		data string
+		want Money
+	}{
+		{`{"amount":"10.00","currency":"USD","minor_units":1000}`, Money{minor: 1000, Currency: USD}},
+		{`{"amount":"10","currency":"USD"}`, Money{minor: 1000, Currency: USD}},
+		{`{"currency":"JPY","minor_units":500}`, Money{minor: 500, Currency: JPY}},
+		{`{"amount":"-1.5","currency":"KWD"}`, Money{minor: -1500, Currency: KWD}},
+	}
+
+	for _, tt := range tests {
+		var m Money
+		require.NoError(t, json.Unmarshal([]byte(tt.data), &m), tt.data)
+		assert.Equal(t, tt.want, m, tt.data)
+	}
+}
+
+func Test_Money_UnmarshalJSON_RejectsInvalidObjects(t *testing.T) {
+	tests := []string{
+		`{"amount":"10.00"}`,
+		`{"amount":"10.00","currency":"XXX"}`,
+		`{"currency":"USD"}`,
+		`{"amount":"10.001","currency":"USD"}`,
+		`{"amount":"10.00","currency":"USD","minor_units":100}`,
+		`{"amount":"abc","currency":"USD"}`,
+		`{"currency":"USD","minor_units":-9223372036854775808}`,
+	}
+
+	for _, data := range tests {
+		m := Money{minor: 1, Currency: GEL}
+		assert.Error(t, json.Unmarshal([]byte(data), &m), data)
+		assert.Equal(t, Money{minor: 1, Currency: GEL}, m, "failed decode must not modify the value")
+	}
+}
+
+func Test_Money_UnmarshalJSON_AcceptsLegacyStrings(t *testing.T) {
+	var m Money
+	require.NoError(t, json.Unmarshal([]byte(`"₾12.34"`), &m))
+	assert.Equal(t, Money{minor: 1234, Currency: GEL}, m)
+
+	type lineItem struct {
+		Amount Money `json:"amount"`
+	}
+
+	var item lineItem
+	require.NoError(t, json.Unmarshal([]byte(`{"amount":"-$5.00"}`), &item))
+	assert.Equal(t, Money{minor: -500, Currency: USD}, item.Amount)
+}
+
+func Test_Money_UnmarshalJSON_RejectsStringWithoutCurrency(t *testing.T) {
+	m := Money{minor: 1, Currency: GEL}
+	assert.Error(t, json.Unmarshal([]byte(`"10.00"`), &m))
+	assert.Equal(t, GEL, m.Currency)
+}
+
+func Test_Money_JSON_RoundTripsInBothFormats(t *testing.T) {
+	for _, format := range []JSONFormat{JSONFormatObject, JSONFormatString} {
+		for _, m := range []Money{{minor: 123456, Currency: USD}, {minor: -7, Currency: KWD}, {minor: 0, Currency: JPY}} {
+			data := marshalJSON(t, m, format)
+
+			var decoded Money
+			require.NoError(t, json.Unmarshal(data, &decoded), string(data))
+			assert.Equal(t, m, decoded, format)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package money
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Locale is a BCP 47 language tag such as "en-US" or "ka-GE".
+type Locale string
+
+const (
+	LocaleEnUS Locale = "en-US"
+	LocaleEnGB Locale = "en-GB"
+	LocaleKaGE Locale = "ka-GE"
+	LocaleDeDE Locale = "de-DE"
+	LocaleFrFR Locale = "fr-FR"
+)
+
+// DefaultLocale is used when neither the request nor the customer specifies one.
+const DefaultLocale = LocaleEnUS
+
+// LocaleInfo describes how amounts are written in a locale.
+type LocaleInfo struct {
+	Tag          Locale
+	Group        string
+	Decimal      string
+	SymbolAfter  bool
+	SymbolSpaced bool
+}
+
+var locales = map[Locale]LocaleInfo{
+	LocaleEnUS: {Tag: LocaleEnUS, Group: ",", Decimal: "."},
+	LocaleEnGB: {Tag: LocaleEnGB, Group: ",", Decimal: "."},
+	LocaleKaGE: {Tag: LocaleKaGE, Group: " ", Decimal: ",", SymbolAfter: true, SymbolSpaced: true},
+	LocaleDeDE: {Tag: LocaleDeDE, Group: ".", Decimal: ",", SymbolAfter: true, SymbolSpaced: true},
+	LocaleFrFR: {Tag: LocaleFrFR, Group: " ", Decimal: ",", SymbolAfter: true, SymbolSpaced: true},
+}
+
+// ParseLocale accepts tags in any case with "-" or "_", e.g. "ka_ge".
+// A bare language such as "ka" resolves to the supported locale for that language.
+func ParseLocale(s string) (Locale, error) {
+	parts := strings.FieldsFunc(strings.TrimSpace(s), func(r rune) bool { return r == '-' || r == '_' })
+
+	switch len(parts) {
+	case 1:
+		prefix := strings.ToLower(parts[0]) + "-"
+		if strings.HasPrefix(string(DefaultLocale), prefix) {
+			return DefaultLocale, nil
+		}
+
+		tags := make([]string, 0, len(locales))
+		for tag := range locales {
+			tags = append(tags, string(tag))
+		}
+
+		sort.Strings(tags)
+
+		for _, tag := range tags {
+			if strings.HasPrefix(tag, prefix) {
+				return Locale(tag), nil
+			}
+		}
+	case 2:
+		locale := Locale(strings.ToLower(parts[0]) + "-" + strings.ToUpper(parts[1]))
+		if _, ok := locales[locale]; ok {
+			return locale, nil
+		}
+	}
+
+	return "", fmt.Errorf("unsupported locale: %q", s)
+}
+
+// MatchLocale picks the supported locale with the highest weight from an
+// Accept-Language header, e.g. "ka-GE,ka;q=0.9,en;q=0.8".
+func MatchLocale(acceptLanguage string) (Locale, bool) {
+	type candidate struct {
+		locale Locale
+		weight float64
+	}
+
+	var candidates []candidate
+
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+
+		weight := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(q, 64)
+			if err != nil {
+				continue
+			}
+
+			weight = parsed
+		}
+
+		locale, err := ParseLocale(tag)
+		if err != nil || weight <= 0 {
+			continue
+		}
+
+		candidates = append(candidates, candidate{locale: locale, weight: weight})
+	}
+
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].weight > candidates[j].weight })
+
+	return candidates[0].locale, true
+}
+
+func (l Locale) info() LocaleInfo {
+	if info, ok := locales[l]; ok {
+		return info
+	}
+
+	return locales[DefaultLocale]
+}
+
+// Format writes m the way the locale expects, e.g. "$1,234.56" for en-US and
+// "1 234,56 ₾" for ka-GE. Unsupported locales fall back to DefaultLocale.
+func (m Money) Format(locale Locale) string {
+	return formatAmount(minorToDecimalString(m.minor, m.Currency.MinorUnits()), m.Currency, locale)
+}
+
+func (p Precise) Format(locale Locale) string {
+	return formatAmount(preciseString(p.amount, p.Currency.MinorUnits()), p.Currency, locale)
+}
+
+func formatAmount(amount string, currency Currency, locale Locale) string {
+	info := locale.info()
+
+	sign := ""
+	if strings.HasPrefix(amount, "-") {
+		sign, amount = "-", amount[1:]
+	}
+
+	integer, fraction, _ := strings.Cut(amount, ".")
+
+	var b strings.Builder
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteString(info.Group)
+		}
+
+		b.WriteRune(digit)
+	}
+
+	if fraction != "" {
+		b.WriteString(info.Decimal)
+		b.WriteString(fraction)
+	}
+
+	symbol := currency.Symbol()
+
+	if info.SymbolAfter {
+		if info.SymbolSpaced {
+			return sign + b.String() + " " + symbol
+		}
+
+		return sign + b.String() + symbol
+	}
+
+	// letter symbols such as "KD" would run into the digits
+	if info.SymbolSpaced || unicode.IsLetter([]rune(symbol)[len([]rune(symbol))-1]) {
+		return sign + symbol + " " + b.String()
+	}
+
+	return sign + symbol + b.String()
+}
@@ -0,0 +1,74 @@
+package money
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Money_Format_UsesLocaleConventions(t *testing.T) {
+	tests := []struct {
+		m      Money
+		locale Locale
+		want   string
+	}{
+		{Money{minor: 123456, Currency: USD}, LocaleEnUS, "$1,234.56"},
+		{Money{minor: 123456, Currency: GEL}, LocaleKaGE, "1 234,56 ₾"},
+		{Money{minor: 123456789, Currency: EUR}, LocaleDeDE, "1.234.567,89 €"},
+		{Money{minor: 99, Currency: GBP}, LocaleEnGB, "£0.99"},
+		{Money{minor: -123456, Currency: USD}, LocaleEnUS, "-$1,234.56"},
+		{Money{minor: -123456, Currency: GEL}, LocaleKaGE, "-1 234,56 ₾"},
+		{Money{minor: 1234567, Currency: JPY}, LocaleEnUS, "¥1,234,567"},
+		{Money{minor: 1234567, Currency: KWD}, LocaleEnUS, "KD 1,234.567"},
+		{Money{minor: 100000, Currency: USD}, "xx-XX", "$1,000.00"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.m.Format(tt.locale))
+	}
+}
+
+func Test_Precise_Format_KeepsSubCentDigits(t *testing.T) {
+	p := NewPrecise(decimal.RequireFromString("1234.0004"), GEL)
+	assert.Equal(t, "1 234,0004 ₾", p.Format(LocaleKaGE))
+}
+
+func Test_ParseLocale_NormalizesTags(t *testing.T) {
+	tests := map[string]Locale{
+		"en-US": LocaleEnUS,
+		"ka_ge": LocaleKaGE,
+		"ka":    LocaleKaGE,
+		"en":    LocaleEnUS,
+		"DE-de": LocaleDeDE,
+	}
+
+	for input, want := range tests {
+		got, err := ParseLocale(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+
+	_, err := ParseLocale("xx-XX")
+	assert.Error(t, err)
+
+	_, err = ParseLocale("")
+	assert.Error(t, err)
+}
+
+func Test_MatchLocale_PrefersHighestWeight(t *testing.T) {
+	locale, ok := MatchLocale("ka-GE,ka;q=0.9,en;q=0.8")
+	require.True(t, ok)
+	assert.Equal(t, LocaleKaGE, locale)
+
+	locale, ok = MatchLocale("zh-CN, en;q=0.5, de-DE;q=0.7")
+	require.True(t, ok)
+	assert.Equal(t, LocaleDeDE, locale)
+
+	_, ok = MatchLocale("zh-CN, *;q=0.1")
+	assert.False(t, ok)
+
+	_, ok = MatchLocale("")
+	assert.False(t, ok)
+}
@@ -0,0 +1,133 @@
+package money
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+// MarkupMode decides how a conversion markup is shown to the customer.
+type MarkupMode string
+
+const (
+	// MarkupSeparateFee converts at the mid rate and charges the markup as its own line item.
+	MarkupSeparateFee MarkupMode = "SEPARATE_FEE"
+	// MarkupInRate folds the markup into the applied rate and the converted amount.
+	MarkupInRate MarkupMode = "IN_RATE"
+)
+
+func (m MarkupMode) Validate() error {
+	switch m {
+	case MarkupSeparateFee, MarkupInRate:
+		return nil
+	default:
+		return fmt.Errorf("invalid markup mode: %q", m)
+	}
+}
+
+type CurrencyPair struct {
+	From Currency
+	To   Currency
+}
+
+// Markup is the spread charged on a conversion: a percentage of the converted
+// amount and/or a fixed fee in the target currency.
+type Markup struct {
+	Percent decimal.Decimal `json:"percent"`
+	Fixed   decimal.Decimal `json:"fixed"`
+}
+
+func (mk Markup) IsZero() bool {
+	return mk.Percent.IsZero() && mk.Fixed.IsZero()
+}
+
+func (mk Markup) Validate() error {
+	if mk.Percent.IsNegative() || mk.Fixed.IsNegative() {
+		return fmt.Errorf("markup cannot be negative")
+	}
+
+	return nil
+}
+
+// Fee returns the markup on an amount that was converted at the mid rate.
+func (mk Markup) Fee(converted Money, opts ...Option) (Money, error) {
+	fee, err := converted.Percent(mk.Percent, opts...)
+	if err != nil {
+		return Money{}, err
+	}
+
+	fixed, err := fromDecimal(mk.Fixed, converted.Currency, opts...)
+	if err != nil {
+		return Money{}, err
+	}
+
+	return fee.Add(fixed)
+}
+
+// ApplyToRate folds the percentage markup into rate. The fixed part cannot be
+// expressed as a rate and has to be added to the converted amount separately.
+func (mk Markup) ApplyToRate(rate Rate) Rate {
+	rate.Value = rate.Value.Mul(decimal.NewFromInt(1).Add(mk.Percent.Div(hundred)))
+	return rate
+}
+
+// MarkedUp is an amount converted with a markup. Fee is the part of the charge
+// that is markup: with MarkupInRate it is already included in Amount and Rate,
+// otherwise Amount is at the mid rate and Fee is charged on top of it.
+type MarkedUp struct {
+	Amount Money
+	Fee    Money
+	Rate   Rate
+}
+
+// Convert converts amount at the mid rate with the markup charged as mode says.
+func (mk Markup) Convert(amount Money, rate Rate, mode MarkupMode, opts ...Option) (MarkedUp, error) {
+	atMidRate, err := amount.Convert(rate, opts...)
+	if err != nil {
+		return MarkedUp{}, err
+	}
+
+	if mode != MarkupInRate {
+		fee, err := mk.Fee(atMidRate, opts...)
+		if err != nil {
+			return MarkedUp{}, err
+		}
+
+		return MarkedUp{Amount: atMidRate, Fee: fee, Rate: rate}, nil
+	}
+
+	applied := mk.ApplyToRate(rate)
+
+	converted, err := amount.Convert(applied, opts...)
+	if err != nil {
+		return MarkedUp{}, err
+	}
+
+	fixed, err := fromDecimal(mk.Fixed, rate.To, opts...)
+	if err != nil {
+		return MarkedUp{}, err
+	}
+
+	if converted, err = converted.Add(fixed); err != nil {
+		return MarkedUp{}, err
+	}
+
+	fee, err := converted.Sub(atMidRate)
+	if err != nil {
+		return MarkedUp{}, err
+	}
+
+	return MarkedUp{Amount: converted, Fee: fee, Rate: applied}, nil
+}
+
+// Markups holds the configured markup for each currency pair.
+type Markups map[CurrencyPair]Markup
+
+func (m Markups) For(from, to Currency) (Markup, bool) {
+	markup, ok := m[CurrencyPair{From: from, To: to}]
+	if !ok || markup.IsZero() {
+		return Markup{}, false
+	}
+
+	return markup, true
+}
@@ -0,0 +1,114 @@
+package money
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Markup_Fee_CombinesPercentAndFixed(t *testing.T) {
+	converted := New(decimal.NewFromFloat(36.00), USD)
+
+	fee, err := Markup{Percent: decimal.NewFromInt(2)}.Fee(converted)
+	require.NoError(t, err)
+	assert.Equal(t, int64(72), fee.minor)
+	assert.Equal(t, USD, fee.Currency)
+
+	fee, err = Markup{Percent: decimal.NewFromFloat(1.5), Fixed: decimal.NewFromFloat(0.30)}.Fee(converted)
+	require.NoError(t, err)
+	assert.Equal(t, int64(84), fee.minor)
+
+	fee, err = Markup{Fixed: decimal.NewFromInt(1)}.Fee(New(decimal.NewFromInt(5000), JPY))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), fee.minor)
+}
+
+func Test_Markup_ApplyToRate_FoldsPercentIntoRate(t *testing.T) {
+	rate := Rate{From: GEL, To: USD, Value: decimal.NewFromFloat(0.4)}
+
+	applied := Markup{Percent: decimal.NewFromInt(2), Fixed: decimal.NewFromInt(1)}.ApplyToRate(rate)
+	assert.True(t, decimal.NewFromFloat(0.408).Equal(applied.Value))
+	assert.Equal(t, GEL, applied.From)
+	assert.Equal(t, USD, applied.To)
+
+	converted, err := New(decimal.NewFromInt(100), GEL).Convert(applied)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4080), converted.minor)
+}
+
+func Test_Markup_Validate(t *testing.T) {
+	assert.NoError(t, Markup{Percent: decimal.NewFromInt(2)}.Validate())
+	assert.Error(t, Markup{Percent: decimal.NewFromInt(-2)}.Validate())
+	assert.Error(t, Markup{Fixed: decimal.NewFromInt(-1)}.Validate())
+}
+
+func Test_Markups_For_LooksUpPair(t *testing.T) {
+	markups := Markups{
+		{From: GEL, To: USD}: {Percent: decimal.NewFromInt(2)},
+		{From: EUR, To: USD}: {},
+	}
+
+	markup, ok := markups.For(GEL, USD)
+	assert.True(t, ok)
+	assert.True(t, decimal.NewFromInt(2).Equal(markup.Percent))
+
+	_, ok = markups.For(USD, GEL)
+	assert.False(t, ok)
+
+	_, ok = markups.For(EUR, USD)
+	assert.False(t, ok)
+}
+
+func Test_MarkupMode_Validate(t *testing.T) {
+	assert.NoError(t, MarkupSeparateFee.Validate())
+	assert.NoError(t, MarkupInRate.Validate())
+	assert.Error(t, MarkupMode("HIDDEN").Validate())
+}
+
+func Test_Markup_Convert(t *testing.T) {
+	rate := Rate{From: GEL, To: USD, Value: decimal.NewFromFloat(0.4)}
+
+	tests := []struct {
+		name     string
+		markup   Markup
+		mode     MarkupMode
+		amount   Money
+		amountTo int64
+		fee      int64
+		rate     string
+	}{
+		{"separate percent", Markup{Percent: decimal.NewFromInt(2)}, MarkupSeparateFee, New(decimal.NewFromInt(100), GEL), 4000, 80, "0.4"},
+		{"separate percent and fixed", Markup{Percent: decimal.NewFromInt(2), Fixed: decimal.NewFromFloat(0.30)}, MarkupSeparateFee, New(decimal.NewFromInt(100), GEL), 4000, 110, "0.4"},
+		{"separate fee rounds to zero", Markup{Percent: decimal.NewFromInt(1)}, MarkupSeparateFee, New(decimal.NewFromFloat(0.10), GEL), 4, 0, "0.4"},
+		{"unset mode charges a separate fee", Markup{Percent: decimal.NewFromInt(2)}, "", New(decimal.NewFromInt(100), GEL), 4000, 80, "0.4"},
+		{"in rate percent", Markup{Percent: decimal.NewFromInt(2)}, MarkupInRate, New(decimal.NewFromInt(100), GEL), 4080, 80, "0.408"},
+		{"in rate fixed", Markup{Fixed: decimal.NewFromFloat(0.30)}, MarkupInRate, New(decimal.NewFromInt(100), GEL), 4030, 30, "0.4"},
+		{"in rate percent and fixed", Markup{Percent: decimal.NewFromInt(2), Fixed: decimal.NewFromFloat(0.30)}, MarkupInRate, New(decimal.NewFromInt(100), GEL), 4110, 110, "0.408"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			converted, err := tt.markup.Convert(tt.amount, rate, tt.mode)
+			require.NoError(t, err)
+
+			assert.Equal(t, Money{minor: tt.amountTo, Currency: USD}, converted.Amount)
+			assert.Equal(t, Money{minor: tt.fee, Currency: USD}, converted.Fee)
+			assert.Equal(t, tt.rate, converted.Rate.Value.String())
+		})
+	}
+}
+
+func Test_Markup_Convert_ReturnsErrors(t *testing.T) {
+	rate := Rate{From: GEL, To: USD, Value: decimal.NewFromFloat(0.4)}
+	huge := Markup{Fixed: decimal.RequireFromString("1e30")}
+
+	for _, mode := range []MarkupMode{MarkupSeparateFee, MarkupInRate} {
+		_, err := huge.Convert(New(decimal.NewFromInt(100), GEL), rate, mode)
+		assert.ErrorIs(t, err, ErrOverflow, mode)
+
+		_, err = Markup{Percent: decimal.NewFromInt(2)}.Convert(New(decimal.NewFromInt(100), USD), rate, mode)
+		assert.Error(t, err, mode)
+	}
+}
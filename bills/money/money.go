@@ -1,34 +1,37 @@
 package money
 
 import (
-	"encoding/json"
 	"fmt"
-	"strings"
+	"math"
+	"time"
 
 	"github.com/shopspring/decimal"
 )
 
 type Money struct {
-	cents    int64
+	minor    int64
 	Currency Currency `json:"currency"`
 }
 
-func New(amount decimal.Decimal, currency Currency) Money {
-	return Money{
-		cents:    decimalToCents(amount),
-		Currency: currency,
+// New rounds amount to the currency's minor units. It panics if the result does
+// not fit in int64 minor units, so amounts from user input or arithmetic should go
+// through NewFromString or the error-returning operations instead.
+func New(amount decimal.Decimal, currency Currency, opts ...Option) Money {
+	m, err := fromDecimal(amount, currency, opts...)
+	if err != nil {
+		panic(err)
 	}
+
+	return m
 }
 
-func (m Money) Add(other Money) (Money, error) {
-	if m.Currency != other.Currency {
-		return Money{}, fmt.Errorf("cannot add different currencies: %s and %s", m.Currency, other.Currency)
+func fromDecimal(amount decimal.Decimal, currency Currency, opts ...Option) (Money, error) {
+	minor, err := decimalToMinor(amount, currency.MinorUnits(), roundingFor(currency, opts))
+	if err != nil {
+		return Money{}, err
 	}
 
-	return Money{
-		cents:    m.cents + other.cents,
-		Currency: m.Currency,
-	}, nil
+	return Money{minor: minor, Currency: currency}, nil
 }
 
 func (m Money) String() string {
@@ -36,26 +39,49 @@ func (m Money) String() string {
 }
 
 func (m Money) validate() error {
-	if m.Currency != USD && m.Currency != GEL {
-		return fmt.Errorf("invalid currency: %s", m.Currency)
+	if err := m.validateSigned(); err != nil {
+		return err
 	}
 
-	if m.cents < 0 {
+	if m.minor < 0 {
 		return fmt.Errorf("amount cannot be negative")
 	}
 
 	return nil
 }
 
+func (m Money) validateSigned() error {
+	if !m.Currency.IsValid() {
+		return fmt.Errorf("invalid currency: %s", m.Currency)
+	}
+
+	return nil
+}
+
 func NewFromString(amount string, currency Currency) (money Money, err error) {
+	return newFromString(amount, currency, Money.validate)
+}
+
+// NewSignedFromString is like NewFromString but also accepts negative amounts,
+// for callers that represent credits, refunds or adjustments.
+func NewSignedFromString(amount string, currency Currency) (money Money, err error) {
+	return newFromString(amount, currency, Money.validateSigned)
+}
+
+func newFromString(amount string, currency Currency, validate func(Money) error) (money Money, err error) {
 	decimal, err := decimal.NewFromString(amount)
 	if err != nil {
 		err = fmt.Errorf("invalid amount format: %w", err)
 		return
 	}
 
-	money = New(decimal, currency)
-	if err = money.validate(); err != nil {
+	money, err = fromDecimal(decimal, currency)
+	if err != nil {
+		err = fmt.Errorf("invalid amount: %w", err)
+		return
+	}
+
+	if err = validate(money); err != nil {
 		err = fmt.Errorf("invalid amount: %w", err)
 		return
 	}
@@ -63,35 +89,48 @@ func NewFromString(amount string, currency Currency) (money Money, err error) {
 	return
 }
 
-func (m Money) ConvertTo(targetCurrency Currency, rates *ExchangeRates) (Money, error) {
+// ConvertTo converts m using the rate that applied at asOf.
+func (m Money) ConvertTo(targetCurrency Currency, rates RateProvider, asOf time.Time, opts ...Option) (Money, error) {
 	if m.Currency == targetCurrency {
 		return m, nil
 	}
 
-	var convertedAmount decimal.Decimal
-	amount := centsToDecimal(m.cents)
+	rate, err := rates.Rate(m.Currency, targetCurrency, asOf)
+	if err != nil {
+		return Money{}, fmt.Errorf("unsupported currency conversion from %s to %s: %w", m.Currency, targetCurrency, err)
+	}
+
+	return m.Convert(rate, opts...)
+}
 
-	if m.Currency == USD && targetCurrency == GEL {
-		convertedAmount = amount.Mul(rates.USDToGEL)
-	} else if m.Currency == GEL && targetCurrency == USD {
-		convertedAmount = amount.Mul(rates.GELToUSD)
-	} else {
-		return Money{}, fmt.Errorf("unsupported currency conversion from %s to %s", m.Currency, targetCurrency)
+func (m Money) Convert(rate Rate, opts ...Option) (Money, error) {
+	if rate.From != m.Currency {
+		return Money{}, fmt.Errorf("cannot convert %s using a %s/%s rate", m.Currency, rate.From, rate.To)
 	}
 
-	return New(convertedAmount, targetCurrency), nil
+	return fromDecimal(m.Amount().Mul(rate.Value), rate.To, opts...)
 }
 
-func decimalToCents(amount decimal.Decimal) int64 {
-	return amount.Mul(decimal.NewFromInt(100)).Round(0).IntPart()
+var (
+	maxMinor = decimal.NewFromInt(math.MaxInt64)
+	minMinor = decimal.NewFromInt(-math.MaxInt64)
+)
+
+func decimalToMinor(amount decimal.Decimal, exp int32, rounding RoundingMode) (int64, error) {
+	minor := rounding.round(amount.Shift(exp), 0)
+	if minor.GreaterThan(maxMinor) || minor.LessThan(minMinor) {
+		return 0, ErrOverflow
+	}
+
+	return minor.IntPart(), nil
 }
 
-func centsToDecimal(cents int64) decimal.Decimal {
-	return decimal.NewFromInt(cents).Div(decimal.NewFromInt(100))
+func minorToDecimal(minor int64, exp int32) decimal.Decimal {
+	return decimal.New(minor, -exp)
 }
 
-func centsToDecimalString(cents int64) string {
-	return fmt.Sprintf("%.2f", float64(cents)/100)
+func minorToDecimalString(minor int64, exp int32) string {
+	return minorToDecimal(minor, exp).StringFixed(exp)
 }
 
 func ZeroAmount() decimal.Decimal {
@@ -99,45 +138,22 @@ func ZeroAmount() decimal.Decimal {
 }
 
 func (m Money) Amount() decimal.Decimal {
-	return centsToDecimal(m.cents)
+	return minorToDecimal(m.minor, m.Currency.MinorUnits())
 }
 
-func (m Money) FormatWithSymbol() string {
-	amount := centsToDecimalString(m.cents)
-	symbol := m.Currency.Symbol()
-
-	return fmt.Sprintf("%s%s", symbol, amount)
+// MinorUnits returns the amount in the currency's smallest unit, e.g. cents for USD.
+func (m Money) MinorUnits() int64 {
+	return m.minor
 }
 
-func (m Money) MarshalJSON() ([]byte, error) {
-	return json.Marshal(m.FormatWithSymbol())
-}
-
-func (m *Money) UnmarshalJSON(data []byte) error {
-	var valueStr string
-	if err := json.Unmarshal(data, &valueStr); err != nil {
-		return err
-	}
-
-	valueStr = strings.TrimSpace(valueStr)
-
-	if len(valueStr) > 0 {
-		if strings.HasPrefix(valueStr, "$") {
-			m.Currency = USD
-			valueStr = strings.TrimPrefix(valueStr, "$")
-		} else if strings.HasPrefix(valueStr, "₾") {
-			m.Currency = GEL
-			valueStr = strings.TrimPrefix(valueStr, "₾")
-		}
-
-		valueStr = strings.TrimSpace(valueStr)
+func (m Money) FormatWithSymbol() string {
+	sign := ""
+	if m.minor < 0 {
+		sign = "-"
 	}
 
-	amount, err := decimal.NewFromString(valueStr)
-	if err != nil {
-		return fmt.Errorf("invalid amount: %v", err)
-	}
+	amount := minorToDecimalString(m.Abs().minor, m.Currency.MinorUnits())
+	symbol := m.Currency.Symbol()
 
-	m.cents = decimalToCents(amount)
-	return m.validate()
+	return fmt.Sprintf("%s%s%s", sign, symbol, amount)
 }
@@ -0,0 +1,99 @@
+package money
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func FuzzNewFromString_RoundTripsThroughJSON(f *testing.F) {
+	for _, seed := range []string{"0", "0.01", "123.45", "9999999999999999.99", "92233720368547758.07", "1e2", "0.005"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, amount string) {
+		m, err := NewFromString(amount, USD)
+		if err != nil {
+			return
+		}
+
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Fatalf("marshal %s: %v", m, err)
+		}
+
+		var decoded Money
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("unmarshal %s: %v", data, err)
+		}
+
+		if !decoded.Equal(m) {
+			t.Fatalf("round trip of %q: got %d, want %d", amount, decoded.minor, m.minor)
+		}
+	})
+}
+
+func FuzzMinorToDecimalString_IsExact(f *testing.F) {
+	for _, seed := range []int64{0, 1, -1, 12345, math.MaxInt64, -math.MaxInt64, 999999999999999999} {
+		f.Add(seed, uint8(2))
+	}
+
+	f.Fuzz(func(t *testing.T, minor int64, exp uint8) {
+		e := int32(exp % (maxMinorUnits + 1))
+
+		formatted := minorToDecimalString(minor, e)
+
+		parsed, err := decimal.NewFromString(formatted)
+		if err != nil {
+			t.Fatalf("unparseable %q: %v", formatted, err)
+		}
+
+		if got := parsed.Shift(e); !got.Equal(decimal.NewFromInt(minor)) {
+			t.Fatalf("%d with exponent %d formatted as %q", minor, e, formatted)
+		}
+	})
+}
+
+func FuzzMoney_AddAndMulInt_MatchBigInt(f *testing.F) {
+	f.Add(int64(1), int64(2))
+	f.Add(int64(math.MaxInt64), int64(1))
+	f.Add(int64(-math.MaxInt64), int64(-1))
+	f.Add(int64(math.MaxInt64), int64(-math.MaxInt64))
+
+	limit := big.NewInt(math.MaxInt64)
+
+	f.Fuzz(func(t *testing.T, a, b int64) {
+		if a == math.MinInt64 || b == math.MinInt64 {
+			return
+		}
+
+		want := new(big.Int).Add(big.NewInt(a), big.NewInt(b))
+		inRange := want.CmpAbs(limit) <= 0
+
+		sum, err := Money{minor: a, Currency: USD}.Add(Money{minor: b, Currency: USD})
+		switch {
+		case inRange && err != nil:
+			t.Fatalf("%d + %d: unexpected error %v", a, b, err)
+		case !inRange && err == nil:
+			t.Fatalf("%d + %d: expected overflow, got %d", a, b, sum.minor)
+		case inRange && sum.minor != want.Int64():
+			t.Fatalf("%d + %d: got %d, want %s", a, b, sum.minor, want)
+		}
+
+		product, err := Money{minor: a, Currency: USD}.MulInt(b)
+		want.Mul(big.NewInt(a), big.NewInt(b))
+		inRange = want.CmpAbs(limit) <= 0
+
+		switch {
+		case inRange && err != nil:
+			t.Fatalf("%d * %d: unexpected error %v", a, b, err)
+		case !inRange && err == nil:
+			t.Fatalf("%d * %d: expected overflow, got %d", a, b, product.minor)
+		case inRange && product.minor != want.Int64():
+			t.Fatalf("%d * %d: got %d, want %s", a, b, product.minor, want)
+		}
+	})
+}
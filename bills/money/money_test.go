@@ -4,17 +4,27 @@ import (
 	"encoding/json"
 	"math"
 	"testing"
+	"time"
 
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-func Test_New_CreatesMoneyWithCorrectCentsAndCurrency(t *testing.T) {
+var rateDate = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func usdRates(gel float64) *RateTable {
+	rates := NewRateTable(USD)
+	rates.Add(Quote{Currency: GEL, Rate: decimal.NewFromFloat(gel), EffectiveAt: rateDate})
+
+	return rates
+}
+
+func Test_New_CreatesMoneyWithCorrectMinorUnitsAndCurrency(t *testing.T) {
 	amount := decimal.NewFromFloat(123.45)
 	m := New(amount, USD)
 
-	assert.Equal(t, int64(12345), m.cents)
+	assert.Equal(t, int64(12345), m.minor)
 	assert.Equal(t, USD, m.Currency)
 }
 
@@ -22,7 +32,7 @@ func Test_NewFromString_ParsesValidAmountString(t *testing.T) {
 	m, err := NewFromString("123.45", USD)
 
 	require.NoError(t, err)
-	assert.Equal(t, int64(12345), m.cents)
+	assert.Equal(t, int64(12345), m.minor)
 	assert.Equal(t, USD, m.Currency)
 }
 
@@ -43,7 +53,7 @@ func Test_Money_Add_CombinesSameMoneyTypes(t *testing.T) {
 
 	result, err := m1.Add(m2)
 	require.NoError(t, err)
-	assert.Equal(t, int64(15000), result.cents)
+	assert.Equal(t, int64(15000), result.minor)
 	assert.Equal(t, USD, result.Currency)
 }
 
@@ -56,12 +66,60 @@ func Test_Money_Add_RejectsAddingDifferentCurrencies(t *testing.T) {
 }
 
 func Test_Money_Add_HandlesPotentialIntegerOverflow(t *testing.T) {
-	m1 := Money{cents: math.MaxInt64 - 100, Currency: USD}
+	m1 := Money{minor: math.MaxInt64 - 100, Currency: USD}
 	m2 := New(decimal.NewFromFloat(1), USD)
 
 	result, err := m1.Add(m2)
 	require.NoError(t, err)
-	assert.Equal(t, int64(math.MaxInt64-100+100), result.cents)
+	assert.Equal(t, int64(math.MaxInt64-100+100), result.minor)
+}
+
+func Test_Money_Add_RejectsOverflow(t *testing.T) {
+	m1 := Money{minor: math.MaxInt64 - 100, Currency: USD}
+	m2 := Money{minor: 101, Currency: USD}
+
+	_, err := m1.Add(m2)
+	assert.ErrorIs(t, err, ErrOverflow)
+
+	_, err = m1.Neg().Sub(m2)
+	assert.ErrorIs(t, err, ErrOverflow)
+
+	_, err = m1.MulInt(2)
+	assert.ErrorIs(t, err, ErrOverflow)
+
+	_, err = m1.Mul(decimal.NewFromFloat(1.5))
+	assert.ErrorIs(t, err, ErrOverflow)
+}
+
+func Test_NewFromString_RejectsAmountsOutOfRange(t *testing.T) {
+	_, err := NewFromString("92233720368547758.08", USD)
+	assert.ErrorIs(t, err, ErrOverflow)
+
+	m, err := NewFromString("92233720368547758.07", USD)
+	require.NoError(t, err)
+	assert.Equal(t, int64(math.MaxInt64), m.minor)
+
+	assert.Panics(t, func() { New(decimal.RequireFromString("1e30"), USD) })
+
+	var u Money
+	assert.Error(t, json.Unmarshal([]byte(`"$1e30"`), &u))
+}
+
+func Test_Money_String_FormatsLargeAmountsExactly(t *testing.T) {
+	tests := []struct {
+		m    Money
+		want string
+	}{
+		{Money{minor: math.MaxInt64, Currency: USD}, "$92233720368547758.07"},
+		{Money{minor: -math.MaxInt64, Currency: USD}, "-$92233720368547758.07"},
+		{Money{minor: 999999999999999999, Currency: USD}, "$9999999999999999.99"},
+		{Money{minor: math.MaxInt64, Currency: KWD}, "KD9223372036854775.807"},
+		{Money{minor: math.MaxInt64, Currency: JPY}, "¥9223372036854775807"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.m.String())
+	}
 }
 
 func Test_Money_String_FormatsCorrectly(t *testing.T) {
@@ -73,94 +131,79 @@ func Test_Money_String_FormatsCorrectly(t *testing.T) {
 }
 
 func Test_Money_Validate_ChecksCurrencyValidity(t *testing.T) {
-	m := Money{cents: 100, Currency: "INVALID"}
+	m := Money{minor: 100, Currency: "INVALID"}
 	assert.Error(t, m.validate())
 
-	m = Money{cents: 100, Currency: USD}
+	m = Money{minor: 100, Currency: USD}
 	assert.NoError(t, m.validate())
 
-	m = Money{cents: 100, Currency: GEL}
+	m = Money{minor: 100, Currency: GEL}
 	assert.NoError(t, m.validate())
 }
 
 func Test_Money_Validate_ChecksNegativeAmounts(t *testing.T) {
-	m := Money{cents: -100, Currency: USD}
+	m := Money{minor: -100, Currency: USD}
 	assert.Error(t, m.validate())
 
-	m = Money{cents: 0, Currency: USD}
+	m = Money{minor: 0, Currency: USD}
 	assert.NoError(t, m.validate())
 
-	m = Money{cents: 100, Currency: USD}
+	m = Money{minor: 100, Currency: USD}
 	assert.NoError(t, m.validate())
 }
 
 func Test_Money_ConvertTo_HandlesIdenticalCurrencies(t *testing.T) {
 	m := New(decimal.NewFromFloat(100), USD)
-	rates := &ExchangeRates{
-		USDToGEL: decimal.NewFromFloat(2.5),
-		GELToUSD: decimal.NewFromFloat(0.4),
-	}
+	rates := usdRates(2.5)
 
-	result, err := m.ConvertTo(USD, rates)
+	result, err := m.ConvertTo(USD, rates, rateDate)
 	require.NoError(t, err)
-	assert.Equal(t, m.cents, result.cents)
+	assert.Equal(t, m.minor, result.minor)
 	assert.Equal(t, m.Currency, result.Currency)
 }
 
 func Test_Money_ConvertTo_ConvertsUSDToGEL(t *testing.T) {
 	m := New(decimal.NewFromFloat(100), USD)
-	rates := &ExchangeRates{
-		USDToGEL: decimal.NewFromFloat(2.5),
-		GELToUSD: decimal.NewFromFloat(0.4),
-	}
+	rates := usdRates(2.5)
 
-	result, err := m.ConvertTo(GEL, rates)
+	result, err := m.ConvertTo(GEL, rates, rateDate)
 	require.NoError(t, err)
-	assert.Equal(t, int64(25000), result.cents)
+	assert.Equal(t, int64(25000), result.minor)
 	assert.Equal(t, GEL, result.Currency)
 }
 
 func Test_Money_ConvertTo_ConvertsGELToUSD(t *testing.T) {
 	m := New(decimal.NewFromFloat(100), GEL)
-	rates := &ExchangeRates{
-		USDToGEL: decimal.NewFromFloat(2.5),
-		GELToUSD: decimal.NewFromFloat(0.4),
-	}
+	rates := usdRates(2.5)
 
-	result, err := m.ConvertTo(USD, rates)
+	result, err := m.ConvertTo(USD, rates, rateDate)
 	require.NoError(t, err)
-	assert.Equal(t, int64(4000), result.cents)
+	assert.Equal(t, int64(4000), result.minor)
 	assert.Equal(t, USD, result.Currency)
 }
 
 func Test_Money_ConvertTo_RejectsUnsupportedCurrencyPairs(t *testing.T) {
-	m := Money{cents: 10000, Currency: "EUR"}
-	rates := &ExchangeRates{
-		USDToGEL: decimal.NewFromFloat(2.5),
-		GELToUSD: decimal.NewFromFloat(0.4),
-	}
+	m := Money{minor: 10000, Currency: "EUR"}
+	rates := usdRates(2.5)
 
-	_, err := m.ConvertTo(USD, rates)
+	_, err := m.ConvertTo(USD, rates, rateDate)
 	assert.Error(t, err)
 
 	m = New(decimal.NewFromFloat(100), USD)
-	_, err = m.ConvertTo("EUR", rates)
+	_, err = m.ConvertTo("EUR", rates, rateDate)
 	assert.Error(t, err)
 }
 
 func Test_Money_ConvertTo_HandlesRoundingEdgeCases(t *testing.T) {
 	m := New(decimal.NewFromFloat(0.01), USD)
-	rates := &ExchangeRates{
-		USDToGEL: decimal.NewFromFloat(2.5),
-		GELToUSD: decimal.NewFromFloat(0.4),
-	}
+	rates := usdRates(2.5)
 
-	result, err := m.ConvertTo(GEL, rates)
+	result, err := m.ConvertTo(GEL, rates, rateDate)
 	require.NoError(t, err)
-	assert.Equal(t, int64(3), result.cents)
+	assert.Equal(t, int64(3), result.minor)
 }
 
-func Test_DecimalToCents_ConvertsCorrectly(t *testing.T) {
+func Test_DecimalToMinor_ConvertsCorrectly(t *testing.T) {
 	tests := []struct {
 		amount decimal.Decimal
 		want   int64
@@ -174,11 +217,13 @@ func Test_DecimalToCents_ConvertsCorrectly(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		assert.Equal(t, tt.want, decimalToCents(tt.amount))
+		got, err := decimalToMinor(tt.amount, 2, RoundHalfAwayFromZero)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got)
 	}
 }
 
-func Test_DecimalToCents_HandlesRounding(t *testing.T) {
+func Test_DecimalToMinor_HandlesRounding(t *testing.T) {
 	tests := []struct {
 		amount decimal.Decimal
 		want   int64
@@ -190,13 +235,15 @@ func Test_DecimalToCents_HandlesRounding(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		assert.Equal(t, tt.want, decimalToCents(tt.amount))
+		got, err := decimalToMinor(tt.amount, 2, RoundHalfAwayFromZero)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got)
 	}
 }
 
-func Test_CentsToDecimal_ConvertsCorrectly(t *testing.T) {
+func Test_MinorToDecimal_ConvertsCorrectly(t *testing.T) {
 	tests := []struct {
-		cents int64
+		minor int64
 		want  decimal.Decimal
 	}{
 		{12345, decimal.NewFromFloat(123.45)},
@@ -205,13 +252,13 @@ func Test_CentsToDecimal_ConvertsCorrectly(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		assert.True(t, tt.want.Equal(centsToDecimal(tt.cents)))
+		assert.True(t, tt.want.Equal(minorToDecimal(tt.minor, 2)))
 	}
 }
 
-func Test_CentsToDecimalString_FormatsWithTwoDecimalPlaces(t *testing.T) {
+func Test_MinorToDecimalString_FormatsWithTwoDecimalPlaces(t *testing.T) {
 	tests := []struct {
-		cents int64
+		minor int64
 		want  string
 	}{
 		{12345, "123.45"},
@@ -221,7 +268,7 @@ func Test_CentsToDecimalString_FormatsWithTwoDecimalPlaces(t *testing.T) {
 	}
 
 	for _, tt := range tests {
-		assert.Equal(t, tt.want, centsToDecimalString(tt.cents))
+		assert.Equal(t, tt.want, minorToDecimalString(tt.minor, 2))
 	}
 }
 
@@ -231,38 +278,34 @@ func Test_ZeroAmount_ReturnsZeroDecimal(t *testing.T) {
 }
 
 func Test_Money_Amount_ReturnsCorrectDecimal(t *testing.T) {
-	m := Money{cents: 12345, Currency: USD}
+	m := Money{minor: 12345, Currency: USD}
 	expected := decimal.NewFromFloat(123.45)
 	assert.True(t, expected.Equal(m.Amount()))
 }
 
 func Test_Money_FormatWithSymbol_FormatsUSDCorrectly(t *testing.T) {
-	m := Money{cents: 12345, Currency: USD}
+	m := Money{minor: 12345, Currency: USD}
 	assert.Equal(t, "$123.45", m.FormatWithSymbol())
 }
 
 func Test_Money_FormatWithSymbol_FormatsGELCorrectly(t *testing.T) {
-	m := Money{cents: 12345, Currency: GEL}
+	m := Money{minor: 12345, Currency: GEL}
 	assert.Equal(t, "₾123.45", m.FormatWithSymbol())
 }
 
 func Test_Money_MarshalJSON_SerializesWithCurrencySymbol(t *testing.T) {
-	m := Money{cents: 12345, Currency: USD}
-	data, err := json.Marshal(m)
-	require.NoError(t, err)
-	assert.Equal(t, `"$123.45"`, string(data))
+	m := Money{minor: 12345, Currency: USD}
+	assert.Equal(t, `"$123.45"`, string(marshalJSON(t, m, JSONFormatString)))
 
-	m = Money{cents: 12345, Currency: GEL}
-	data, err = json.Marshal(m)
-	require.NoError(t, err)
-	assert.Equal(t, `"₾123.45"`, string(data))
+	m = Money{minor: 12345, Currency: GEL}
+	assert.Equal(t, `"₾123.45"`, string(marshalJSON(t, m, JSONFormatString)))
 }
 
 func Test_Money_UnmarshalJSON_ParsesUSDFormat(t *testing.T) {
 	var m Money
 	err := json.Unmarshal([]byte(`"$123.45"`), &m)
 	require.NoError(t, err)
-	assert.Equal(t, int64(12345), m.cents)
+	assert.Equal(t, int64(12345), m.minor)
 	assert.Equal(t, USD, m.Currency)
 }
 
@@ -270,7 +313,7 @@ func Test_Money_UnmarshalJSON_ParsesGELFormat(t *testing.T) {
 	var m Money
 	err := json.Unmarshal([]byte(`"₾123.45"`), &m)
 	require.NoError(t, err)
-	assert.Equal(t, int64(12345), m.cents)
+	assert.Equal(t, int64(12345), m.minor)
 	assert.Equal(t, GEL, m.Currency)
 }
 
@@ -278,7 +321,7 @@ func Test_Money_UnmarshalJSON_HandlesWhitespace(t *testing.T) {
 	var m Money
 	err := json.Unmarshal([]byte(`" $123.45 "`), &m)
 	require.NoError(t, err)
-	assert.Equal(t, int64(12345), m.cents)
+	assert.Equal(t, int64(12345), m.minor)
 	assert.Equal(t, USD, m.Currency)
 }
 
@@ -287,24 +330,21 @@ func Test_Money_UnmarshalJSON_RejectsInvalidFormats(t *testing.T) {
 	err := json.Unmarshal([]byte(`"invalid"`), &m)
 	assert.Error(t, err)
 
-	err = json.Unmarshal([]byte(`"€123.45"`), &m)
+	err = json.Unmarshal([]byte(`"₿123.45"`), &m)
 	assert.Error(t, err)
 }
 
 func Test_Money_ConvertTo_MaintainsPrecisionDuringConversion(t *testing.T) {
 	m := New(decimal.NewFromFloat(0.01), USD)
-	rates := &ExchangeRates{
-		USDToGEL: decimal.NewFromFloat(2.5),
-		GELToUSD: decimal.NewFromFloat(0.4),
-	}
+	rates := usdRates(2.5)
 
-	result, err := m.ConvertTo(GEL, rates)
+	result, err := m.ConvertTo(GEL, rates, rateDate)
 	require.NoError(t, err)
 
-	backToUSD, err := result.ConvertTo(USD, rates)
+	backToUSD, err := result.ConvertTo(USD, rates, rateDate)
 	require.NoError(t, err)
 
-	assert.Equal(t, int64(1), backToUSD.cents)
+	assert.Equal(t, int64(1), backToUSD.minor)
 }
 
 func Test_Money_Add_MaintainsPrecisionDuringAddition(t *testing.T) {
@@ -313,45 +353,44 @@ func Test_Money_Add_MaintainsPrecisionDuringAddition(t *testing.T) {
 
 	result, err := m1.Add(m2)
 	require.NoError(t, err)
-	assert.Equal(t, int64(3), result.cents)
+	assert.Equal(t, int64(3), result.minor)
 }
 
 func Test_Money_UnmarshalJSON_HandlesEmptyString(t *testing.T) {
 	var m Money
 	err := json.Unmarshal([]byte(`"$0"`), &m)
 	require.NoError(t, err)
-	assert.Equal(t, int64(0), m.cents)
+	assert.Equal(t, int64(0), m.minor)
 	assert.Equal(t, USD, m.Currency)
 }
 
 func Test_Money_ConvertTo_HandlesZeroAmounts(t *testing.T) {
 	m := New(decimal.NewFromFloat(0), USD)
-	rates := &ExchangeRates{
-		USDToGEL: decimal.NewFromFloat(2.5),
-		GELToUSD: decimal.NewFromFloat(0.4),
-	}
+	rates := usdRates(2.5)
 
-	result, err := m.ConvertTo(GEL, rates)
+	result, err := m.ConvertTo(GEL, rates, rateDate)
 	require.NoError(t, err)
-	assert.Equal(t, int64(0), result.cents)
+	assert.Equal(t, int64(0), result.minor)
 	assert.Equal(t, GEL, result.Currency)
 }
 
 func Test_Money_UnmarshalJSON_HandlesNegativeValues(t *testing.T) {
 	var m Money
 	err := json.Unmarshal([]byte(`"-$123.45"`), &m)
+	require.NoError(t, err)
+	assert.Equal(t, int64(-12345), m.minor)
+	assert.Equal(t, USD, m.Currency)
+
+	err = json.Unmarshal([]byte(`"-invalid"`), &m)
 	assert.Error(t, err)
 }
 
 func Test_Money_ConvertTo_HandlesExtremeExchangeRates(t *testing.T) {
 	m := New(decimal.NewFromFloat(100), USD)
-	rates := &ExchangeRates{
-		USDToGEL: decimal.NewFromFloat(1000000),
-		GELToUSD: decimal.NewFromFloat(0.000001),
-	}
+	rates := usdRates(1000000)
 
-	result, err := m.ConvertTo(GEL, rates)
+	result, err := m.ConvertTo(GEL, rates, rateDate)
 	require.NoError(t, err)
-	assert.Equal(t, int64(10000000000), result.cents)
+	assert.Equal(t, int64(10000000000), result.minor)
 	assert.Equal(t, GEL, result.Currency)
 }
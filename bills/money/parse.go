@@ -0,0 +1,183 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/shopspring/decimal"
+)
+
+// ErrCurrencyMismatch is returned by Parse when the string names a different
+// currency than the one it was parsed for.
+var ErrCurrencyMismatch = errors.New("currency mismatch")
+
+// Parse reads a user-entered amount such as "1,234.50", "10 GEL", "USD 10",
+// "₾10" or "1 234,50 ₾". Grouping and decimal marks follow locale, and an empty
+// locale means DefaultLocale. currency may be empty when the string names one.
+func Parse(s string, currency Currency, locale Locale) (Money, error) {
+	found, number, err := splitCurrency(s)
+	if err != nil {
+		return Money{}, err
+	}
+
+	switch {
+	case found != "" && currency != "" && found != currency:
+		return Money{}, fmt.Errorf("%w: %q is in %s, expected %s", ErrCurrencyMismatch, s, found, currency)
+	case found == "" && currency == "":
+		return Money{}, fmt.Errorf("missing currency in %q", s)
+	case found == "":
+		found = currency
+	}
+
+	if !found.IsValid() {
+		return Money{}, fmt.Errorf("invalid currency: %s", found)
+	}
+
+	amount, err := parseNumber(number, locale.info())
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q: %w", s, err)
+	}
+
+	m, err := fromDecimal(amount, found)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q: %w", s, err)
+	}
+
+	if err := m.validate(); err != nil {
+		return Money{}, err
+	}
+
+	return m, nil
+}
+
+// splitCurrency separates a currency code or symbol from either end of s,
+// keeping a leading minus sign with the number.
+func splitCurrency(s string) (currency Currency, number string, err error) {
+	s = strings.TrimSpace(s)
+
+	sign := ""
+	if rest, ok := strings.CutPrefix(s, "-"); ok {
+		sign, s = "-", strings.TrimSpace(rest)
+	}
+
+	prefix, s := cutCurrencyPrefix(s)
+	suffix, s := cutCurrencySuffix(strings.TrimSpace(s))
+
+	switch {
+	case prefix != "" && suffix != "" && prefix != suffix:
+		return "", "", fmt.Errorf("%w: %q names both %s and %s", ErrCurrencyMismatch, sign+s, prefix, suffix)
+	case prefix != "":
+		currency = prefix
+	default:
+		currency = suffix
+	}
+
+	return currency, sign + strings.TrimSpace(s), nil
+}
+
+func cutCurrencyPrefix(s string) (Currency, string) {
+	if len(s) >= 3 && !startsWithLetter(s[3:]) {
+		if code := Currency(strings.ToUpper(s[:3])); code.IsValid() {
+			return code, s[3:]
+		}
+	}
+
+	if currency, rest, ok := currencyFromSymbolPrefix(s); ok {
+		return currency, rest
+	}
+
+	return "", s
+}
+
+func cutCurrencySuffix(s string) (Currency, string) {
+	if n := len(s); n >= 3 && !endsWithLetter(s[:n-3]) {
+		if code := Currency(strings.ToUpper(s[n-3:])); code.IsValid() {
+			return code, s[:n-3]
+		}
+	}
+
+	longest := 0
+	var currency Currency
+
+	for _, info := range Currencies() {
+		if len(info.Symbol) > longest && strings.HasSuffix(s, info.Symbol) {
+			currency, longest = info.Code, len(info.Symbol)
+		}
+	}
+
+	return currency, s[:len(s)-longest]
+}
+
+func startsWithLetter(s string) bool {
+	for _, r := range s {
+		return unicode.IsLetter(r)
+	}
+
+	return false
+}
+
+func endsWithLetter(s string) bool {
+	runes := []rune(s)
+	return len(runes) > 0 && unicode.IsLetter(runes[len(runes)-1])
+}
+
+// parseNumber reads a number written with the locale's marks. Spaces are always
+// accepted as grouping, and groups after the first must have three digits, so
+// "1,5" is rejected in en-US instead of being read as 15.
+func parseNumber(s string, info LocaleInfo) (decimal.Decimal, error) {
+	sign := ""
+	if rest, ok := strings.CutPrefix(s, "-"); ok {
+		sign, s = "-", rest
+	}
+
+	if s == "" {
+		return decimal.Decimal{}, fmt.Errorf("missing number")
+	}
+
+	integer, fraction, hasFraction := strings.Cut(s, info.Decimal)
+
+	groups := strings.Split(strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || string(r) == info.Group {
+			return ','
+		}
+
+		return r
+	}, integer), ",")
+
+	for i, group := range groups {
+		if !isDigits(group) {
+			return decimal.Decimal{}, fmt.Errorf("unexpected characters %q for locale %s", integer, info.Tag)
+		}
+
+		if len(groups) > 1 && (len(group) > 3 || i > 0 && len(group) != 3) {
+			return decimal.Decimal{}, fmt.Errorf("invalid digit grouping %q for locale %s", integer, info.Tag)
+		}
+	}
+
+	if hasFraction && !isDigits(fraction) {
+		return decimal.Decimal{}, fmt.Errorf("unexpected characters %q for locale %s", fraction, info.Tag)
+	}
+
+	digits := sign + strings.Join(groups, "")
+	if hasFraction {
+		digits += "." + fraction
+	}
+
+	return decimal.NewFromString(digits)
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,75 @@
+package money
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Parse_AcceptsCommonInputs(t *testing.T) {
+	tests := []struct {
+		input    string
+		currency Currency
+		locale   Locale
+		want     Money
+	}{
+		{"1,234.50", USD, LocaleEnUS, Money{minor: 123450, Currency: USD}},
+		{"10 GEL", GEL, LocaleEnUS, Money{minor: 1000, Currency: GEL}},
+		{"USD 10", USD, LocaleEnUS, Money{minor: 1000, Currency: USD}},
+		{"usd10", "", LocaleEnUS, Money{minor: 1000, Currency: USD}},
+		{"₾10", GEL, LocaleEnUS, Money{minor: 1000, Currency: GEL}},
+		{"10₾", "", LocaleEnUS, Money{minor: 1000, Currency: GEL}},
+		{"1 234,56 ₾", GEL, LocaleKaGE, Money{minor: 123456, Currency: GEL}},
+		{"1 234,56", GEL, LocaleKaGE, Money{minor: 123456, Currency: GEL}},
+		{"1.234.567,89 EUR", EUR, LocaleDeDE, Money{minor: 123456789, Currency: EUR}},
+		{"$1,000,000", USD, "", Money{minor: 100000000, Currency: USD}},
+		{"KD 1.5", KWD, LocaleEnUS, Money{minor: 1500, Currency: KWD}},
+		{"  42  ", USD, LocaleEnUS, Money{minor: 4200, Currency: USD}},
+		{"1234.5", USD, LocaleEnUS, Money{minor: 123450, Currency: USD}},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.input, tt.currency, tt.locale)
+		require.NoError(t, err, tt.input)
+		assert.Equal(t, tt.want, got, tt.input)
+	}
+}
+
+func Test_Parse_RejectsConflictingCurrencies(t *testing.T) {
+	for _, input := range []string{"$10", "EUR 10", "USD 10 GEL"} {
+		_, err := Parse(input, GEL, LocaleEnUS)
+		assert.ErrorIs(t, err, ErrCurrencyMismatch, input)
+	}
+
+	_, err := Parse("10 GEL", USD, LocaleEnUS)
+	assert.ErrorContains(t, err, "is in GEL, expected USD")
+}
+
+func Test_Parse_RejectsMalformedInputs(t *testing.T) {
+	tests := []struct {
+		input    string
+		currency Currency
+		locale   Locale
+	}{
+		{"1,5", USD, LocaleEnUS},
+		{"1,2345.00", USD, LocaleEnUS},
+		{"1.234,50", USD, LocaleEnUS},
+		{"1,234.", USD, LocaleEnUS},
+		{",234", USD, LocaleEnUS},
+		{"1,,234", USD, LocaleEnUS},
+		{"12a", USD, LocaleEnUS},
+		{"", USD, LocaleEnUS},
+		{"USD", USD, LocaleEnUS},
+		{"10", "", LocaleEnUS},
+		{"10 XXX", "", LocaleEnUS},
+		{"-10", USD, LocaleEnUS},
+		{"1.234,50", GEL, LocaleKaGE},
+		{"92,233,720,368,547,758.08", USD, LocaleEnUS},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.input, tt.currency, tt.locale)
+		assert.Error(t, err, tt.input)
+	}
+}
@@ -0,0 +1,145 @@
+package money
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// PreciseScale is the number of decimal places kept by Precise, i.e. nano-units
+// of the major unit. Usage prices like $0.0004 per call fit comfortably.
+const PreciseScale int32 = 9
+
+// Precise is an amount kept at PreciseScale instead of the currency's minor units.
+// Use it for per-unit prices and running totals that must only be rounded once,
+// with Round, when the final amount is known.
+type Precise struct {
+	amount   decimal.Decimal
+	Currency Currency `json:"currency"`
+}
+
+func NewPrecise(amount decimal.Decimal, currency Currency) Precise {
+	return Precise{amount: amount.RoundBank(PreciseScale), Currency: currency}
+}
+
+func NewPreciseFromString(amount string, currency Currency) (Precise, error) {
+	if !currency.IsValid() {
+		return Precise{}, fmt.Errorf("invalid currency: %s", currency)
+	}
+
+	value, err := decimal.NewFromString(strings.TrimSpace(amount))
+	if err != nil {
+		return Precise{}, fmt.Errorf("invalid amount: %v", err)
+	}
+
+	if !value.Equal(value.Truncate(PreciseScale)) {
+		return Precise{}, fmt.Errorf("invalid amount: more than %d decimal places", PreciseScale)
+	}
+
+	return NewPrecise(value, currency), nil
+}
+
+// Precise returns m without any loss of precision.
+func (m Money) Precise() Precise {
+	return Precise{amount: m.Amount(), Currency: m.Currency}
+}
+
+func (p Precise) Amount() decimal.Decimal {
+	return p.amount
+}
+
+func (p Precise) Add(other Precise) (Precise, error) {
+	if p.Currency != other.Currency {
+		return Precise{}, fmt.Errorf("cannot add different currencies: %s and %s", p.Currency, other.Currency)
+	}
+
+	return Precise{amount: p.amount.Add(other.amount), Currency: p.Currency}, nil
+}
+
+func (p Precise) Sub(other Precise) (Precise, error) {
+	if p.Currency != other.Currency {
+		return Precise{}, fmt.Errorf("cannot subtract different currencies: %s and %s", p.Currency, other.Currency)
+	}
+
+	return Precise{amount: p.amount.Sub(other.amount), Currency: p.Currency}, nil
+}
+
+func (p Precise) MulInt(quantity int64) Precise {
+	return Precise{amount: p.amount.Mul(decimal.NewFromInt(quantity)), Currency: p.Currency}
+}
+
+func (p Precise) Mul(factor decimal.Decimal) Precise {
+	return NewPrecise(p.amount.Mul(factor), p.Currency)
+}
+
+func (p Precise) IsZero() bool {
+	return p.amount.IsZero()
+}
+
+func (p Precise) IsNegative() bool {
+	return p.amount.IsNegative()
+}
+
+func (p Precise) Equal(other Precise) bool {
+	return p.Currency == other.Currency && p.amount.Equal(other.amount)
+}
+
+// Round converts p to Money using the currency's rounding mode unless overridden.
+func (p Precise) Round(opts ...Option) (Money, error) {
+	return fromDecimal(p.amount, p.Currency, opts...)
+}
+
+// String formats the amount with at least the currency's minor units, e.g. "$0.0004" or "$1.50".
+func (p Precise) String() string {
+	sign := ""
+	if p.amount.IsNegative() {
+		sign = "-"
+	}
+
+	return sign + p.Currency.Symbol() + preciseString(p.amount.Abs(), p.Currency.MinorUnits())
+}
+
+func preciseString(amount decimal.Decimal, minorUnits int32) string {
+	places := minorUnits
+
+	s := amount.String()
+	if i := strings.IndexByte(s, '.'); i >= 0 && int32(len(s)-i-1) > places {
+		places = int32(len(s) - i - 1)
+	}
+
+	return amount.StringFixed(places)
+}
+
+type preciseObject struct {
+	Amount   string   `json:"amount"`
+	Currency Currency `json:"currency"`
+}
+
+func (p Precise) MarshalJSON() ([]byte, error) {
+	return json.Marshal(preciseObject{
+		Amount:   preciseString(p.amount, p.Currency.MinorUnits()),
+		Currency: p.Currency,
+	})
+}
+
+func (p *Precise) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var object preciseObject
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+
+	result, err := NewPreciseFromString(object.Amount, object.Currency)
+	if err != nil {
+		return err
+	}
+
+	*p = result
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_NewPreciseFromString_KeepsSubCentAmounts(t *testing.T) {
+	p, err := NewPreciseFromString("0.0004", USD)
+	require.NoError(t, err)
+	assert.True(t, decimal.RequireFromString("0.0004").Equal(p.Amount()))
+	assert.Equal(t, "$0.0004", p.String())
+
+	_, err = NewPreciseFromString("0.0000000001", USD)
+	assert.Error(t, err)
+
+	_, err = NewPreciseFromString("0.0004", "XXX")
+	assert.Error(t, err)
+}
+
+func Test_Precise_AccumulatesWithoutRounding(t *testing.T) {
+	price, err := NewPreciseFromString("0.0004", USD)
+	require.NoError(t, err)
+
+	total := NewPrecise(ZeroAmount(), USD)
+	for i := 0; i < 1000; i++ {
+		total, err = total.Add(price)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, "$0.40", total.String())
+
+	perItem, err := price.Round()
+	require.NoError(t, err)
+	assert.True(t, perItem.IsZero(), "rounding each call would lose the charge")
+
+	rounded, err := total.Round()
+	require.NoError(t, err)
+	assert.Equal(t, int64(40), rounded.MinorUnits())
+}
+
+func Test_Precise_MulIntAndRound(t *testing.T) {
+	price, err := NewPreciseFromString("0.0004", USD)
+	require.NoError(t, err)
+
+	amount := price.MulInt(12345)
+	assert.Equal(t, "$4.938", amount.String())
+
+	rounded, err := amount.Round()
+	require.NoError(t, err)
+	assert.Equal(t, "$4.94", rounded.String())
+
+	rounded, err = amount.Round(WithRounding(RoundFloor))
+	require.NoError(t, err)
+	assert.Equal(t, "$4.93", rounded.String())
+}
+
+func Test_Precise_RejectsDifferentCurrencies(t *testing.T) {
+	_, err := NewPrecise(decimal.NewFromInt(1), USD).Add(NewPrecise(decimal.NewFromInt(1), GEL))
+	assert.Error(t, err)
+
+	_, err = NewPrecise(decimal.NewFromInt(1), USD).Sub(NewPrecise(decimal.NewFromInt(1), GEL))
+	assert.Error(t, err)
+}
+
+func Test_Money_Precise_IsExact(t *testing.T) {
+	m := Money{minor: 1500, Currency: KWD}
+	assert.True(t, decimal.RequireFromString("1.5").Equal(m.Precise().Amount()))
+	assert.Equal(t, "KD1.500", m.Precise().String())
+}
+
+func Test_Precise_JSON_RoundTrips(t *testing.T) {
+	p := NewPrecise(decimal.RequireFromString("-0.000123"), USD)
+
+	data, err := json.Marshal(p)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"amount":"-0.000123","currency":"USD"}`, string(data))
+
+	var decoded Precise
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.True(t, p.Equal(decoded))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"amount":"1","currency":"XXX"}`), &decoded))
+}
@@ -0,0 +1,303 @@
+package money
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+type RateFormat string
+
+const (
+	// RateFormatECB is the European Central Bank eurofxref XML feed, quoted against EUR.
+	RateFormatECB RateFormat = "ecb"
+	// RateFormatCSV has one "date,from,to,rate" row per rate, with an optional header.
+	RateFormatCSV RateFormat = "csv"
+)
+
+const rateDateLayout = "2006-01-02"
+
+// rateTolerance is how far apart, relative to each other, two rates of the
+// same day may be once rebased before they are considered inconsistent.
+var rateTolerance = decimal.New(1, -9)
+
+func ParseRates(format RateFormat, r io.Reader) ([]Rate, error) {
+	switch format {
+	case RateFormatECB:
+		return ParseECBRates(r)
+	case RateFormatCSV:
+		return ParseCSVRates(r)
+	default:
+		return nil, fmt.Errorf("unsupported rate format: %q", format)
+	}
+}
+
+type ecbEnvelope struct {
+	Days []struct {
+		Time  string `xml:"time,attr"`
+		Rates []struct {
+			Currency string `xml:"currency,attr"`
+			Rate     string `xml:"rate,attr"`
+		} `xml:"Cube"`
+	} `xml:"Cube>Cube"`
+}
+
+// ParseECBRates reads an eurofxref daily or historical XML file.
+// Currencies missing from the registry are skipped, since the feed covers far more
+// currencies than we bill in.
+func ParseECBRates(r io.Reader) ([]Rate, error) {
+	var envelope ecbEnvelope
+	if err := xml.NewDecoder(r).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("invalid ECB rate file: %w", err)
+	}
+
+	if len(envelope.Days) == 0 {
+		return nil, fmt.Errorf("ECB rate file contains no rates")
+	}
+
+	seen := make(map[string]bool, len(envelope.Days))
+	var rates []Rate
+
+	for _, day := range envelope.Days {
+		if seen[day.Time] {
+			return nil, fmt.Errorf("duplicate date in ECB rate file: %s", day.Time)
+		}
+
+		seen[day.Time] = true
+
+		effectiveAt, err := time.Parse(rateDateLayout, day.Time)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q in ECB rate file", day.Time)
+		}
+
+		for _, quote := range day.Rates {
+			currency := Currency(strings.TrimSpace(quote.Currency))
+			if !currency.IsValid() {
+				continue
+			}
+
+			value, err := decimal.NewFromString(quote.Rate)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s rate %q on %s", currency, quote.Rate, day.Time)
+			}
+
+			rates = append(rates, Rate{From: EUR, To: currency, Value: value, EffectiveAt: effectiveAt})
+		}
+	}
+
+	return rates, nil
+}
+
+// ParseCSVRates reads "date,from,to,rate" rows, e.g. "2025-01-10,USD,GEL,2.7777".
+func ParseCSVRates(r io.Reader) ([]Rate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 4
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV rate file: %w", err)
+	}
+
+	if len(records) > 0 && strings.EqualFold(records[0][0], "date") {
+		records = records[1:]
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("CSV rate file contains no rates")
+	}
+
+	type pairKey struct {
+		date     string
+		from, to Currency
+	}
+
+	seen := make(map[pairKey]bool, len(records))
+	rates := make([]Rate, 0, len(records))
+
+	for i, record := range records {
+		effectiveAt, err := time.Parse(rateDateLayout, record[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid date %q", i+1, record[0])
+		}
+
+		from, to := Currency(strings.ToUpper(record[1])), Currency(strings.ToUpper(record[2]))
+
+		key := pairKey{date: record[0], from: from, to: to}
+		if seen[key] {
+			return nil, fmt.Errorf("line %d: duplicate %s/%s rate for %s", i+1, from, to, record[0])
+		}
+
+		seen[key] = true
+
+		value, err := decimal.NewFromString(record[3])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid rate %q", i+1, record[3])
+		}
+
+		rates = append(rates, Rate{From: from, To: to, Value: value, EffectiveAt: effectiveAt})
+	}
+
+	return rates, nil
+}
+
+// QuoteChange describes how an imported quote differs from the one that
+// applied at the same time before the import. Previous is nil when no rate applied.
+// Overwritten is set when the import replaced a quote stored for the same date.
+type QuoteChange struct {
+	Currency    Currency         `json:"currency"`
+	EffectiveAt time.Time        `json:"effective_at"`
+	Previous    *decimal.Decimal `json:"previous,omitempty"`
+	Current     decimal.Decimal  `json:"current"`
+	Overwritten bool             `json:"overwritten,omitempty"`
+}
+
+func (c QuoteChange) String() string {
+	date := c.EffectiveAt.Format(rateDateLayout)
+
+	if c.Previous == nil {
+		return fmt.Sprintf("%s %s: new rate %s", c.Currency, date, c.Current)
+	}
+
+	change := c.Current.Sub(*c.Previous).Div(*c.Previous).Mul(hundred).StringFixed(2)
+	if !strings.HasPrefix(change, "-") {
+		change = "+" + change
+	}
+
+	if c.Overwritten {
+		return fmt.Sprintf("%s %s: replaced %s -> %s (%s%%)", c.Currency, date, c.Previous, c.Current, change)
+	}
+
+	return fmt.Sprintf("%s %s: %s -> %s (%s%%)", c.Currency, date, c.Previous, c.Current, change)
+}
+
+// Import rebases rates onto the table's base currency and stores them.
+// Nothing is stored unless every rate is valid and can be related to the base
+// currency through the other rates of the same day, and those rates agree.
+// Quotes already stored for the same currency and date are only replaced with overwrite.
+func (t *RateTable) Import(rates []Rate, overwrite bool) ([]QuoteChange, error) {
+	byDate := make(map[time.Time][]Rate)
+
+	for _, rate := range rates {
+		if !rate.From.IsValid() || !rate.To.IsValid() {
+			return nil, fmt.Errorf("invalid currency pair %s/%s", rate.From, rate.To)
+		}
+
+		if rate.From == rate.To {
+			return nil, fmt.Errorf("invalid currency pair %s/%s", rate.From, rate.To)
+		}
+
+		if !rate.Value.IsPositive() {
+			return nil, fmt.Errorf("%s/%s rate must be positive", rate.From, rate.To)
+		}
+
+		effectiveAt := rate.EffectiveAt.UTC()
+		byDate[effectiveAt] = append(byDate[effectiveAt], rate)
+	}
+
+	var quotes []Quote
+	for effectiveAt, dayRates := range byDate {
+		dayQuotes, err := t.rebase(effectiveAt, dayRates)
+		if err != nil {
+			return nil, err
+		}
+
+		quotes = append(quotes, dayQuotes...)
+	}
+
+	sort.Slice(quotes, func(i, j int) bool {
+		if quotes[i].Currency != quotes[j].Currency {
+			return quotes[i].Currency < quotes[j].Currency
+		}
+
+		return quotes[i].EffectiveAt.Before(quotes[j].EffectiveAt)
+	})
+
+	for _, q := range quotes {
+		if err := t.validateQuote(q); err != nil {
+			return nil, err
+		}
+	}
+
+	changes := make([]QuoteChange, 0, len(quotes))
+
+	// held across the check and the writes, so a concurrent import cannot
+	// store the same dates in between
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, q := range quotes {
+		change := QuoteChange{Currency: q.Currency, EffectiveAt: q.EffectiveAt, Current: q.Rate}
+
+		if previous, err := t.quoteAt(q.Currency, q.EffectiveAt); err == nil {
+			change.Previous = &previous.Rate
+			change.Overwritten = previous.EffectiveAt.Equal(q.EffectiveAt)
+		}
+
+		if change.Overwritten && !overwrite {
+			return nil, fmt.Errorf("%s rate for %s is already imported", q.Currency, q.EffectiveAt.Format(rateDateLayout))
+		}
+
+		changes = append(changes, change)
+	}
+
+	for _, q := range quotes {
+		t.add(q)
+	}
+
+	return changes, nil
+}
+
+func (t *RateTable) rebase(effectiveAt time.Time, rates []Rate) ([]Quote, error) {
+	known := map[Currency]decimal.Decimal{t.base: decimal.NewFromInt(1)}
+
+	for progress := true; progress; {
+		progress = false
+
+		for _, rate := range rates {
+			fromRate, fromKnown := known[rate.From]
+			toRate, toKnown := known[rate.To]
+
+			switch {
+			case fromKnown && !toKnown:
+				known[rate.To] = fromRate.Mul(rate.Value)
+				progress = true
+			case toKnown && !fromKnown:
+				known[rate.From] = toRate.Div(rate.Value)
+				progress = true
+			}
+		}
+	}
+
+	quotes := make([]Quote, 0, len(known)-1)
+
+	for _, rate := range rates {
+		for _, currency := range []Currency{rate.From, rate.To} {
+			if _, ok := known[currency]; !ok {
+				return nil, fmt.Errorf("cannot relate %s to %s on %s", currency, t.base, effectiveAt.Format(rateDateLayout))
+			}
+		}
+	}
+
+	// rates not used to derive a quote must agree with the ones that were
+	for _, rate := range rates {
+		expected := known[rate.From].Mul(rate.Value)
+		if expected.Sub(known[rate.To]).Abs().GreaterThan(known[rate.To].Mul(rateTolerance)) {
+			return nil, fmt.Errorf("inconsistent %s/%s rate on %s", rate.From, rate.To, effectiveAt.Format(rateDateLayout))
+		}
+	}
+
+	for currency, rate := range known {
+		if currency != t.base {
+			quotes = append(quotes, Quote{Currency: currency, Rate: rate, EffectiveAt: effectiveAt})
+		}
+	}
+
+	return quotes, nil
+}
@@ -0,0 +1,227 @@
+package money
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const ecbSample = `<?xml version="1.0" encoding="UTF-8"?>
+<gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref">
+	<gesmes:subject>Reference rates</gesmes:subject>
+	<gesmes:Sender>
+		<gesmes:name>European Central Bank</gesmes:name>
+	</gesmes:Sender>
+	<Cube>
+		<Cube time="2025-01-10">
+			<Cube currency="USD" rate="1.0304"/>
+			<Cube currency="JPY" rate="162.74"/>
+			<Cube currency="CHF" rate="0.9392"/>
+		</Cube>
+		<Cube time="2025-01-09">
+			<Cube currency="USD" rate="1.0305"/>
+		</Cube>
+	</Cube>
+</gesmes:Envelope>`
+
+func Test_ParseECBRates_ReadsRegisteredCurrencies(t *testing.T) {
+	rates, err := ParseECBRates(strings.NewReader(ecbSample))
+	require.NoError(t, err)
+	require.Len(t, rates, 3)
+
+	assert.Equal(t, EUR, rates[0].From)
+	assert.Equal(t, USD, rates[0].To)
+	assert.True(t, decimal.RequireFromString("1.0304").Equal(rates[0].Value))
+	assert.Equal(t, time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC), rates[0].EffectiveAt)
+	assert.Equal(t, JPY, rates[1].To)
+}
+
+func Test_ParseECBRates_RejectsDuplicateDates(t *testing.T) {
+	data := strings.Replace(ecbSample, `time="2025-01-09"`, `time="2025-01-10"`, 1)
+
+	_, err := ParseECBRates(strings.NewReader(data))
+	assert.Error(t, err)
+}
+
+func Test_ParseECBRates_RejectsInvalidInput(t *testing.T) {
+	_, err := ParseECBRates(strings.NewReader("not xml"))
+	assert.Error(t, err)
+
+	_, err = ParseECBRates(strings.NewReader(strings.Replace(ecbSample, `rate="1.0304"`, `rate="abc"`, 1)))
+	assert.Error(t, err)
+
+	_, err = ParseECBRates(strings.NewReader(strings.Replace(ecbSample, `time="2025-01-10"`, `time="10/01/2025"`, 1)))
+	assert.Error(t, err)
+}
+
+func Test_ParseCSVRates_ReadsRows(t *testing.T) {
+	rates, err := ParseCSVRates(strings.NewReader("date,from,to,rate\n2025-01-10,USD,GEL,2.79\n2025-01-10, eur, usd, 1.03\n"))
+	require.NoError(t, err)
+	require.Len(t, rates, 2)
+
+	assert.Equal(t, USD, rates[0].From)
+	assert.Equal(t, GEL, rates[0].To)
+	assert.Equal(t, EUR, rates[1].From)
+	assert.True(t, decimal.RequireFromString("1.03").Equal(rates[1].Value))
+}
+
+func Test_ParseCSVRates_RejectsInvalidInput(t *testing.T) {
+	tests := []string{
+		"",
+		"date,from,to,rate\n",
+		"2025-01-10,USD,GEL\n",
+		"2025-13-10,USD,GEL,2.79\n",
+		"2025-01-10,USD,GEL,abc\n",
+		"2025-01-10,USD,GEL,2.79\n2025-01-10,USD,GEL,2.80\n",
+	}
+
+	for _, data := range tests {
+		_, err := ParseCSVRates(strings.NewReader(data))
+		assert.Error(t, err, data)
+	}
+}
+
+func Test_ParseRates_RejectsUnknownFormat(t *testing.T) {
+	_, err := ParseRates("xlsx", strings.NewReader(""))
+	assert.Error(t, err)
+}
+
+func Test_RateTable_Import_RebasesOntoBaseCurrency(t *testing.T) {
+	rates, err := ParseECBRates(strings.NewReader(ecbSample))
+	require.NoError(t, err)
+
+	table := NewRateTable(USD)
+	changes, err := table.Import(rates, false)
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+
+	day := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	rate, err := table.Rate(EUR, USD, day)
+	require.NoError(t, err)
+	assert.True(t, decimal.RequireFromString("1.0304").Equal(rate.Value.Round(8)))
+
+	rate, err = table.Rate(EUR, JPY, day)
+	require.NoError(t, err)
+	assert.True(t, decimal.RequireFromString("162.74").Equal(rate.Value.Round(8)))
+
+	for _, change := range changes {
+		assert.Nil(t, change.Previous)
+	}
+}
+
+func Test_RateTable_Import_ReportsChanges(t *testing.T) {
+	table := usdRates(2.5)
+
+	changes, err := table.Import([]Rate{
+		{From: USD, To: GEL, Value: decimal.RequireFromString("2.75"), EffectiveAt: rateDate.AddDate(0, 0, 1)},
+	}, false)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+
+	require.NotNil(t, changes[0].Previous)
+	assert.True(t, decimal.RequireFromString("2.5").Equal(*changes[0].Previous))
+	assert.Equal(t, "GEL 2025-01-02: 2.5 -> 2.75 (+10.00%)", changes[0].String())
+}
+
+func Test_RateTable_Import_OverwritesStoredDatesOnlyWhenAsked(t *testing.T) {
+	table := usdRates(2.5)
+	rates := []Rate{{From: USD, To: GEL, Value: decimal.RequireFromString("2.75"), EffectiveAt: rateDate}}
+
+	_, err := table.Import(rates, false)
+	assert.EqualError(t, err, "GEL rate for 2025-01-01 is already imported")
+	assert.True(t, decimal.RequireFromString("2.5").Equal(table.Quotes()[0].Rate))
+
+	changes, err := table.Import(rates, true)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.True(t, changes[0].Overwritten)
+	assert.Equal(t, "GEL 2025-01-01: replaced 2.5 -> 2.75 (+10.00%)", changes[0].String())
+	assert.True(t, decimal.RequireFromString("2.75").Equal(table.Quotes()[0].Rate))
+}
+
+func Test_RateTable_Import_StoresConcurrentImportsOfADateOnce(t *testing.T) {
+	table := NewRateTable(USD)
+	effectiveAt := rateDate.AddDate(0, 0, 1)
+
+	var wg sync.WaitGroup
+	imported := make(chan error, 20)
+
+	for i := 0; i < cap(imported); i++ {
+		rates := []Rate{{From: USD, To: GEL, Value: decimal.NewFromInt(int64(i + 1)), EffectiveAt: effectiveAt}}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := table.Import(rates, false)
+			imported <- err
+		}()
+	}
+
+	wg.Wait()
+	close(imported)
+
+	succeeded := 0
+	for err := range imported {
+		if err == nil {
+			succeeded++
+		}
+	}
+
+	assert.Equal(t, 1, succeeded)
+	assert.Len(t, table.Quotes(), 1)
+}
+
+func Test_RateTable_Import_RejectsInconsistentRates(t *testing.T) {
+	table := NewRateTable(USD)
+
+	_, err := table.Import([]Rate{
+		{From: USD, To: GEL, Value: decimal.RequireFromString("2.75"), EffectiveAt: rateDate},
+		{From: GEL, To: USD, Value: decimal.RequireFromString("0.5"), EffectiveAt: rateDate},
+	}, false)
+	assert.EqualError(t, err, "inconsistent GEL/USD rate on 2025-01-01")
+	assert.Empty(t, table.Quotes())
+
+	_, err = table.Import([]Rate{
+		{From: USD, To: GEL, Value: decimal.RequireFromString("2.5"), EffectiveAt: rateDate},
+		{From: GEL, To: USD, Value: decimal.RequireFromString("0.4"), EffectiveAt: rateDate},
+	}, false)
+	assert.NoError(t, err)
+}
+
+func Test_RateTable_Import_RejectsUnrelatedCurrencies(t *testing.T) {
+	table := NewRateTable(USD)
+
+	_, err := table.Import([]Rate{
+		{From: EUR, To: GEL, Value: decimal.RequireFromString("2.9"), EffectiveAt: rateDate},
+	}, false)
+	assert.Error(t, err)
+	assert.Empty(t, table.Quotes())
+
+	_, err = table.Import([]Rate{{From: USD, To: GEL, Value: decimal.Zero, EffectiveAt: rateDate}}, false)
+	assert.Error(t, err)
+
+	_, err = table.Import([]Rate{{From: USD, To: "XXX", Value: decimal.NewFromInt(1), EffectiveAt: rateDate}}, false)
+	assert.Error(t, err)
+}
+
+func Test_RateTable_Save_RoundTripsThroughLoad(t *testing.T) {
+	table := usdRates(2.7777)
+	path := filepath.Join(t.TempDir(), "rates.json")
+
+	require.NoError(t, table.Save(path))
+
+	loaded, err := LoadRateTable(path)
+	require.NoError(t, err)
+	assert.Equal(t, table.Base(), loaded.Base())
+	require.Len(t, loaded.Quotes(), 1)
+	assert.True(t, table.Quotes()[0].Rate.Equal(loaded.Quotes()[0].Rate))
+	assert.True(t, table.Quotes()[0].EffectiveAt.Equal(loaded.Quotes()[0].EffectiveAt))
+}
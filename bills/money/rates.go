@@ -1,26 +1,221 @@
 package money
 
-import "github.com/shopspring/decimal"
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
 
-type ExchangeRates struct {
-	USDToGEL decimal.Decimal
-	GELToUSD decimal.Decimal
+	"github.com/shopspring/decimal"
+)
+
+// Rate is the number of units of To that one unit of From buys,
+// effective from EffectiveAt.
+type Rate struct {
+	From        Currency        `json:"from"`
+	To          Currency        `json:"to"`
+	Value       decimal.Decimal `json:"rate"`
+	EffectiveAt time.Time       `json:"effective_at"`
 }
 
-type Currency string
+// RateProvider returns the exchange rate that applied between two currencies at a point in time.
+type RateProvider interface {
+	Rate(from, to Currency, asOf time.Time) (Rate, error)
+}
 
-const (
-	USD Currency = "USD"
-	GEL Currency = "GEL"
-)
+// Quote prices one unit of a table's base currency in Currency, effective from EffectiveAt.
+type Quote struct {
+	Currency    Currency        `json:"currency"`
+	Rate        decimal.Decimal `json:"rate"`
+	EffectiveAt time.Time       `json:"effective_at"`
+}
+
+// RateTable is an in-memory RateProvider holding dated quotes against a single
+// base currency. Every rate, including the reverse of a quote and the cross rate
+// between two non-base currencies, is derived by dividing the stored quotes, so a
+// pair and its reverse never drift apart. The division is rounded to
+// decimal.DivisionPrecision digits though, so they are only exact inverses when
+// that division terminates, e.g. 1/2.5 but not 1/3.
+type RateTable struct {
+	mu     sync.RWMutex
+	base   Currency
+	quotes map[Currency][]Quote
+}
+
+func NewRateTable(base Currency) *RateTable {
+	return &RateTable{
+		base:   base,
+		quotes: make(map[Currency][]Quote),
+	}
+}
+
+func (t *RateTable) Base() Currency {
+	return t.base
+}
+
+// Add stores a quote, replacing any quote for the same currency and effective time.
+func (t *RateTable) Add(q Quote) error {
+	if err := t.validateQuote(q); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.add(q)
+
+	return nil
+}
+
+func (t *RateTable) validateQuote(q Quote) error {
+	if !q.Currency.IsValid() {
+		return fmt.Errorf("invalid currency: %s", q.Currency)
+	}
+
+	if q.Currency == t.base {
+		return fmt.Errorf("cannot quote base currency %s against itself", t.base)
+	}
+
+	if !q.Rate.IsPositive() {
+		return fmt.Errorf("rate for %s must be positive", q.Currency)
+	}
 
-func (c Currency) Symbol() string {
-	switch c {
-	case USD:
-		return "$"
-	case GEL:
-		return "₾"
-	default:
-		return string(c)
+	return nil
+}
+
+// add stores a valid quote. The caller must hold the write lock.
+func (t *RateTable) add(q Quote) {
+	q.EffectiveAt = q.EffectiveAt.UTC()
+
+	quotes := t.quotes[q.Currency]
+	i := sort.Search(len(quotes), func(i int) bool { return !quotes[i].EffectiveAt.Before(q.EffectiveAt) })
+
+	if i < len(quotes) && quotes[i].EffectiveAt.Equal(q.EffectiveAt) {
+		quotes[i] = q
+		return
 	}
+
+	quotes = append(quotes, Quote{})
+	copy(quotes[i+1:], quotes[i:])
+	quotes[i] = q
+	t.quotes[q.Currency] = quotes
+}
+
+// Quotes returns every stored quote ordered by currency and effective time.
+func (t *RateTable) Quotes() []Quote {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	currencies := make([]Currency, 0, len(t.quotes))
+	for currency := range t.quotes {
+		currencies = append(currencies, currency)
+	}
+
+	sort.Slice(currencies, func(i, j int) bool { return currencies[i] < currencies[j] })
+
+	var quotes []Quote
+	for _, currency := range currencies {
+		quotes = append(quotes, t.quotes[currency]...)
+	}
+
+	return quotes
+}
+
+func (t *RateTable) Rate(from, to Currency, asOf time.Time) (Rate, error) {
+	if from == to {
+		return Rate{From: from, To: to, Value: decimal.NewFromInt(1)}, nil
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	fromQuote, err := t.quoteAt(from, asOf)
+	if err != nil {
+		return Rate{}, err
+	}
+
+	toQuote, err := t.quoteAt(to, asOf)
+	if err != nil {
+		return Rate{}, err
+	}
+
+	effectiveAt := fromQuote.EffectiveAt
+	if toQuote.EffectiveAt.After(effectiveAt) {
+		effectiveAt = toQuote.EffectiveAt
+	}
+
+	return Rate{
+		From:        from,
+		To:          to,
+		Value:       toQuote.Rate.Div(fromQuote.Rate),
+		EffectiveAt: effectiveAt,
+	}, nil
+}
+
+func (t *RateTable) quoteAt(currency Currency, asOf time.Time) (Quote, error) {
+	if currency == t.base {
+		return Quote{Currency: t.base, Rate: decimal.NewFromInt(1)}, nil
+	}
+
+	quotes := t.quotes[currency]
+	i := sort.Search(len(quotes), func(i int) bool { return quotes[i].EffectiveAt.After(asOf) })
+
+	if i == 0 {
+		return Quote{}, fmt.Errorf("no %s/%s rate available as of %s", t.base, currency, asOf.UTC().Format(time.RFC3339))
+	}
+
+	return quotes[i-1], nil
+}
+
+type rateTableFile struct {
+	Base   Currency `json:"base"`
+	Quotes []Quote  `json:"quotes"`
+}
+
+// LoadRateTable reads a JSON rate file of the form
+// {"base": "USD", "quotes": [{"currency": "GEL", "rate": "2.7777", "effective_at": "2025-01-01T00:00:00Z"}]}.
+func LoadRateTable(path string) (*RateTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rate file: %w", err)
+	}
+
+	var file rateTableFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse rate file: %w", err)
+	}
+
+	if !file.Base.IsValid() {
+		return nil, fmt.Errorf("invalid base currency: %s", file.Base)
+	}
+
+	table := NewRateTable(file.Base)
+	for _, q := range file.Quotes {
+		if err := table.Add(q); err != nil {
+			return nil, fmt.Errorf("invalid quote in rate file: %w", err)
+		}
+	}
+
+	return table, nil
+}
+
+// Save writes the table in the format read by LoadRateTable.
+func (t *RateTable) Save(path string) error {
+	data, err := json.MarshalIndent(rateTableFile{Base: t.base, Quotes: t.Quotes()}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode rate file: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write rate file: %w", err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write rate file: %w", err)
+	}
+
+	return nil
 }
@@ -0,0 +1,123 @@
+package money
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RateTable_Rate_UsesQuoteEffectiveAtAsOf(t *testing.T) {
+	day1 := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+
+	rates := NewRateTable(USD)
+	require.NoError(t, rates.Add(Quote{Currency: GEL, Rate: decimal.NewFromFloat(2.5), EffectiveAt: day1}))
+	require.NoError(t, rates.Add(Quote{Currency: GEL, Rate: decimal.NewFromFloat(2.8), EffectiveAt: day2}))
+
+	rate, err := rates.Rate(USD, GEL, day1.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromFloat(2.5).Equal(rate.Value))
+	assert.Equal(t, day1, rate.EffectiveAt)
+
+	rate, err = rates.Rate(USD, GEL, day2.Add(time.Hour))
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromFloat(2.8).Equal(rate.Value))
+	assert.Equal(t, day2, rate.EffectiveAt)
+
+	_, err = rates.Rate(USD, GEL, day1.Add(-time.Second))
+	assert.Error(t, err)
+}
+
+func Test_RateTable_Rate_DerivesReverseFromQuote(t *testing.T) {
+	rates := usdRates(2.5)
+
+	rate, err := rates.Rate(GEL, USD, rateDate)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromFloat(0.4).Equal(rate.Value))
+	assert.Equal(t, GEL, rate.From)
+	assert.Equal(t, USD, rate.To)
+
+	// a reverse that does not terminate is rounded, so it is not an exact inverse
+	rates = usdRates(3)
+
+	rate, err = rates.Rate(GEL, USD, rateDate)
+	require.NoError(t, err)
+	assert.Equal(t, "0.3333333333333333", rate.Value.String())
+	assert.False(t, rate.Value.Mul(decimal.NewFromInt(3)).Equal(decimal.NewFromInt(1)))
+}
+
+func Test_RateTable_Rate_DerivesCrossRates(t *testing.T) {
+	rates := usdRates(2.5)
+	require.NoError(t, rates.Add(Quote{Currency: EUR, Rate: decimal.NewFromFloat(0.5), EffectiveAt: rateDate}))
+
+	rate, err := rates.Rate(EUR, GEL, rateDate)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromInt(5).Equal(rate.Value))
+
+	m, err := New(decimal.NewFromInt(10), EUR).ConvertTo(GEL, rates, rateDate)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5000), m.minor)
+}
+
+func Test_RateTable_Add_RejectsInvalidQuotes(t *testing.T) {
+	rates := NewRateTable(USD)
+
+	assert.Error(t, rates.Add(Quote{Currency: "XXX", Rate: decimal.NewFromInt(1)}))
+	assert.Error(t, rates.Add(Quote{Currency: USD, Rate: decimal.NewFromInt(1)}))
+	assert.Error(t, rates.Add(Quote{Currency: GEL, Rate: decimal.NewFromInt(0)}))
+	assert.Error(t, rates.Add(Quote{Currency: GEL, Rate: decimal.NewFromInt(-1)}))
+}
+
+func Test_RateTable_Add_ReplacesQuoteForSameDate(t *testing.T) {
+	rates := usdRates(2.5)
+	require.NoError(t, rates.Add(Quote{Currency: GEL, Rate: decimal.NewFromFloat(2.6), EffectiveAt: rateDate}))
+
+	assert.Len(t, rates.Quotes(), 1)
+
+	rate, err := rates.Rate(USD, GEL, rateDate)
+	require.NoError(t, err)
+	assert.True(t, decimal.NewFromFloat(2.6).Equal(rate.Value))
+}
+
+func Test_Money_Convert_RejectsMismatchedRate(t *testing.T) {
+	_, err := New(decimal.NewFromInt(1), EUR).Convert(Rate{From: USD, To: GEL, Value: decimal.NewFromInt(2)})
+	assert.Error(t, err)
+}
+
+func Test_LoadRateTable_ReadsJSONFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"base": "USD",
+		"quotes": [
+			{"currency": "GEL", "rate": "2.7777", "effective_at": "2025-01-01T00:00:00Z"},
+			{"currency": "EUR", "rate": "0.92", "effective_at": "2025-01-01T00:00:00Z"}
+		]
+	}`), 0o600))
+
+	rates, err := LoadRateTable(path)
+	require.NoError(t, err)
+	assert.Equal(t, USD, rates.Base())
+	assert.Len(t, rates.Quotes(), 2)
+
+	rate, err := rates.Rate(USD, GEL, rateDate)
+	require.NoError(t, err)
+	assert.True(t, decimal.RequireFromString("2.7777").Equal(rate.Value))
+}
+
+func Test_LoadRateTable_RejectsInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := LoadRateTable(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+
+	path := filepath.Join(dir, "invalid.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"base": "USD", "quotes": [{"currency": "GEL", "rate": "0"}]}`), 0o600))
+
+	_, err = LoadRateTable(path)
+	assert.Error(t, err)
+}
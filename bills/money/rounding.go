@@ -0,0 +1,72 @@
+package money
+
+import (
+	"fmt"
+
+	"github.com/shopspring/decimal"
+)
+
+type RoundingMode string
+
+const (
+	// RoundHalfAwayFromZero rounds 0.5 away from zero (2.5 -> 3, -2.5 -> -3).
+	RoundHalfAwayFromZero RoundingMode = "HALF_AWAY_FROM_ZERO"
+	// RoundHalfEven is banker's rounding (2.5 -> 2, 3.5 -> 4).
+	RoundHalfEven RoundingMode = "HALF_EVEN"
+	// RoundTowardZero truncates the extra digits.
+	RoundTowardZero   RoundingMode = "TOWARD_ZERO"
+	RoundAwayFromZero RoundingMode = "AWAY_FROM_ZERO"
+	RoundFloor        RoundingMode = "FLOOR"
+	RoundCeiling      RoundingMode = "CEILING"
+)
+
+const DefaultRoundingMode = RoundHalfAwayFromZero
+
+func (r RoundingMode) Validate() error {
+	switch r {
+	case RoundHalfAwayFromZero, RoundHalfEven, RoundTowardZero, RoundAwayFromZero, RoundFloor, RoundCeiling:
+		return nil
+	default:
+		return fmt.Errorf("invalid rounding mode: %q", r)
+	}
+}
+
+func (r RoundingMode) round(d decimal.Decimal, places int32) decimal.Decimal {
+	switch r {
+	case RoundHalfEven:
+		return d.RoundBank(places)
+	case RoundTowardZero:
+		return d.RoundDown(places)
+	case RoundAwayFromZero:
+		return d.RoundUp(places)
+	case RoundFloor:
+		return d.RoundFloor(places)
+	case RoundCeiling:
+		return d.RoundCeil(places)
+	default:
+		return d.Round(places)
+	}
+}
+
+// Option customizes how an operation rounds to the currency's minor units.
+type Option func(*options)
+
+type options struct {
+	rounding RoundingMode
+}
+
+// WithRounding overrides the currency's default rounding mode for a single call.
+func WithRounding(mode RoundingMode) Option {
+	return func(o *options) {
+		o.rounding = mode
+	}
+}
+
+func roundingFor(currency Currency, opts []Option) RoundingMode {
+	o := options{rounding: currency.Rounding()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o.rounding
+}
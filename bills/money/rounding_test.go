@@ -0,0 +1,95 @@
+package money
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RoundingMode_RoundsToMinorUnits(t *testing.T) {
+	tests := []struct {
+		amount string
+		mode   RoundingMode
+		want   int64
+	}{
+		{"0.125", RoundHalfAwayFromZero, 13},
+		{"-0.125", RoundHalfAwayFromZero, -13},
+		{"0.125", RoundHalfEven, 12},
+		{"0.135", RoundHalfEven, 14},
+		{"-0.125", RoundHalfEven, -12},
+		{"0.129", RoundTowardZero, 12},
+		{"-0.129", RoundTowardZero, -12},
+		{"0.121", RoundAwayFromZero, 13},
+		{"-0.121", RoundAwayFromZero, -13},
+		{"-0.121", RoundFloor, -13},
+		{"0.129", RoundFloor, 12},
+		{"0.121", RoundCeiling, 13},
+		{"-0.129", RoundCeiling, -12},
+	}
+
+	for _, tt := range tests {
+		got, err := decimalToMinor(decimal.RequireFromString(tt.amount), 2, tt.mode)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "%s %s", tt.amount, tt.mode)
+	}
+}
+
+func Test_RoundingMode_Validate(t *testing.T) {
+	assert.NoError(t, RoundHalfEven.Validate())
+	assert.Error(t, RoundingMode("NEAREST").Validate())
+	assert.Error(t, RoundingMode("").Validate())
+}
+
+func Test_New_UsesCurrencyDefaultRounding(t *testing.T) {
+	require.NoError(t, Register(CurrencyInfo{Code: "XTS", MinorUnits: 2, Rounding: RoundHalfEven}))
+	defer func() {
+		registryMu.Lock()
+		delete(registry, "XTS")
+		registryMu.Unlock()
+	}()
+
+	assert.Equal(t, RoundHalfEven, Currency("XTS").Rounding())
+	assert.Equal(t, int64(12), New(decimal.RequireFromString("0.125"), "XTS").minor)
+	assert.Equal(t, int64(13), New(decimal.RequireFromString("0.125"), USD).minor)
+	assert.Equal(t, DefaultRoundingMode, USD.Rounding())
+}
+
+func Test_Register_RejectsInvalidRounding(t *testing.T) {
+	assert.Error(t, Register(CurrencyInfo{Code: "XTS", MinorUnits: 2, Rounding: "NEAREST"}))
+}
+
+func Test_New_WithRoundingOverridesDefault(t *testing.T) {
+	m := New(decimal.RequireFromString("0.125"), USD, WithRounding(RoundHalfEven))
+	assert.Equal(t, int64(12), m.minor)
+}
+
+func Test_Money_ConvertTo_WithRoundingTruncates(t *testing.T) {
+	m := New(decimal.NewFromFloat(0.04), GEL)
+	rates := usdRates(2.5)
+
+	rounded, err := m.ConvertTo(USD, rates, rateDate)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), rounded.minor)
+
+	truncated, err := m.ConvertTo(USD, rates, rateDate, WithRounding(RoundTowardZero))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), truncated.minor)
+}
+
+func Test_Money_Mul_WithRounding(t *testing.T) {
+	m := New(decimal.NewFromFloat(0.25), USD)
+
+	result, err := m.Mul(decimal.NewFromFloat(0.5))
+	require.NoError(t, err)
+	assert.Equal(t, int64(13), result.minor)
+
+	result, err = m.Mul(decimal.NewFromFloat(0.5), WithRounding(RoundHalfEven))
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), result.minor)
+
+	result, err = m.Percent(decimal.NewFromInt(50), WithRounding(RoundFloor))
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), result.minor)
+}
@@ -0,0 +1,82 @@
+package money
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Value stores the currency code, e.g. "USD".
+func (c Currency) Value() (driver.Value, error) {
+	if !c.IsValid() {
+		return nil, fmt.Errorf("invalid currency: %s", c)
+	}
+
+	return string(c), nil
+}
+
+func (c *Currency) Scan(src any) error {
+	text, err := scanText(src)
+	if err != nil {
+		return fmt.Errorf("cannot scan currency: %w", err)
+	}
+
+	currency := Currency(strings.TrimSpace(text))
+	if !currency.IsValid() {
+		return fmt.Errorf("cannot scan currency: invalid currency %q", text)
+	}
+
+	*c = currency
+
+	return nil
+}
+
+// Value stores the currency code and the exact minor units, e.g. "USD 1000" for $10.00.
+func (m Money) Value() (driver.Value, error) {
+	if err := m.validateSigned(); err != nil {
+		return nil, err
+	}
+
+	return fmt.Sprintf("%s %d", m.Currency, m.minor), nil
+}
+
+func (m *Money) Scan(src any) error {
+	text, err := scanText(src)
+	if err != nil {
+		return fmt.Errorf("cannot scan money: %w", err)
+	}
+
+	code, minorText, ok := strings.Cut(strings.TrimSpace(text), " ")
+	if !ok {
+		return fmt.Errorf("cannot scan money: expected \"<currency> <minor units>\", got %q", text)
+	}
+
+	minor, err := strconv.ParseInt(minorText, 10, 64)
+	if err != nil || minor == math.MinInt64 {
+		return fmt.Errorf("cannot scan money: invalid minor units %q", minorText)
+	}
+
+	result := Money{minor: minor, Currency: Currency(code)}
+	if err := result.validateSigned(); err != nil {
+		return fmt.Errorf("cannot scan money: %w", err)
+	}
+
+	*m = result
+
+	return nil
+}
+
+func scanText(src any) (string, error) {
+	switch v := src.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case nil:
+		return "", fmt.Errorf("unexpected NULL")
+	default:
+		return "", fmt.Errorf("unsupported type %T", src)
+	}
+}
@@ -0,0 +1,67 @@
+package money
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Money_Value_StoresCurrencyAndMinorUnits(t *testing.T) {
+	value, err := Money{minor: 1000, Currency: USD}.Value()
+	require.NoError(t, err)
+	assert.Equal(t, "USD 1000", value)
+
+	value, err = Money{minor: -1500, Currency: KWD}.Value()
+	require.NoError(t, err)
+	assert.Equal(t, "KWD -1500", value)
+
+	_, err = Money{minor: 1000, Currency: "XXX"}.Value()
+	assert.Error(t, err)
+}
+
+func Test_Money_Scan_RoundTripsExactly(t *testing.T) {
+	for _, m := range []Money{
+		{minor: 0, Currency: USD},
+		{minor: 1, Currency: GEL},
+		{minor: -42, Currency: JPY},
+		{minor: math.MaxInt64, Currency: KWD},
+		{minor: -math.MaxInt64, Currency: EUR},
+	} {
+		value, err := m.Value()
+		require.NoError(t, err)
+
+		var scanned Money
+		require.NoError(t, scanned.Scan(value))
+		assert.Equal(t, m, scanned)
+
+		require.NoError(t, scanned.Scan([]byte(value.(string))))
+		assert.Equal(t, m, scanned)
+	}
+}
+
+func Test_Money_Scan_RejectsInvalidValues(t *testing.T) {
+	for _, src := range []any{nil, 1000, "USD", "USD 10.00", "XXX 1000", "USD -9223372036854775808", "USD 9223372036854775808", "1000 USD"} {
+		m := Money{minor: 1, Currency: GEL}
+		assert.Error(t, m.Scan(src), src)
+		assert.Equal(t, Money{minor: 1, Currency: GEL}, m)
+	}
+}
+
+func Test_Currency_ValueAndScan(t *testing.T) {
+	value, err := GEL.Value()
+	require.NoError(t, err)
+	assert.Equal(t, "GEL", value)
+
+	var c Currency
+	require.NoError(t, c.Scan([]byte("JPY")))
+	assert.Equal(t, JPY, c)
+
+	assert.Error(t, c.Scan("XXX"))
+	assert.Error(t, c.Scan(nil))
+	assert.Equal(t, JPY, c)
+
+	_, err = Currency("XXX").Value()
+	assert.Error(t, err)
+}
@@ -2,7 +2,9 @@ package bill
 
 import (
 	"context"
+	stderrors "errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"encore.dev/rlog"
@@ -26,6 +28,16 @@ type Service struct {
 }
 
 func initService() (service *Service, err error) {
+	if config.RatesFile != "" {
+		var rates *money.RateTable
+		if rates, err = money.LoadRateTable(config.RatesFile); err != nil {
+			err = fmt.Errorf("failed to load exchange rates: %v", err)
+			return
+		}
+
+		config.Rates = rates
+	}
+
 	temporalClient, err := client.NewLazyClient(client.Options{HostPort: config.TemporalServerURL})
 	if err != nil {
 		err = fmt.Errorf("failed to create Temporal client: %v", err)
@@ -35,7 +47,11 @@ func initService() (service *Service, err error) {
 	worker := temporalworker.New(temporalClient, config.BillingTaskQueue, temporalworker.Options{})
 
 	worker.RegisterWorkflow(workflow.BillingPeriodWorkflow)
+	worker.RegisterWorkflow(workflow.SubscriptionWorkflow)
+	worker.RegisterWorkflow(workflow.CouponWorkflow)
 	worker.RegisterActivity(workflow.SendBillClosedEmail)
+	worker.RegisterActivity(workflow.SendDunningNotice)
+	worker.RegisterActivity(workflow.SendCreditNoteEmail)
 
 	if err = worker.Start(); err != nil {
 		err = fmt.Errorf("failed to start worker: %v", err)
@@ -60,14 +76,19 @@ func (s *Service) CreateBill(ctx context.Context, params *CreateBillParams) (bil
 		return
 	}
 
+	period, err := params.BillingPeriod()
+	if err != nil {
+		return
+	}
+
+	// no execution timeout, the workflow closes the bill itself when its period ends
 	billID := uuid.New().String()
 	_, err = s.temporalClient.ExecuteWorkflow(
 		ctx,
 		client.StartWorkflowOptions{
-			ID:                       billID,
-			TaskQueue:                config.BillingTaskQueue,
-			SearchAttributes:         map[string]interface{}{"CustomerID": params.CustomerID},
-			WorkflowExecutionTimeout: time.Hour * 24 * 30,
+			ID:               billID,
+			TaskQueue:        config.BillingTaskQueue,
+			SearchAttributes: map[string]interface{}{"CustomerID": params.CustomerID},
 			RetryPolicy: &temporal.RetryPolicy{
 				InitialInterval:    time.Second,
 				BackoffCoefficient: 2.0,
@@ -79,6 +100,8 @@ func (s *Service) CreateBill(ctx context.Context, params *CreateBillParams) (bil
 		billID,
 		params.CustomerID,
 		params.Currency,
+		period,
+		params.Draft,
 	)
 
 	if err != nil {
@@ -86,7 +109,14 @@ func (s *Service) CreateBill(ctx context.Context, params *CreateBillParams) (bil
 		return
 	}
 
-	return s.getBill(ctx, billID, params.CustomerID)
+	if bill, err = s.getBill(ctx, billID, params.CustomerID); err != nil {
+		return
+	}
+
+	bill = s.applyCustomerCoupons(ctx, bill)
+	localize(bill, params.Locale, params.Accept)
+
+	return
 }
 
 // AddLineItem adds a line item to a bill.
@@ -102,77 +132,403 @@ func (s *Service) AddLineItem(ctx context.Context, billID string, params *AddLin
 		return
 	}
 
-	if bill.Status == workflow.BillStatusClosed {
-		err = errors.BadRequestError("bill is closed")
-		return
+	createdAt := time.Now().UTC()
+
+	var lineItems []workflow.LineItem
+	if params.UnitPrice != "" {
+		lineItems, err = usageLineItem(params, bill.Currency, createdAt)
+	} else {
+		var amount money.Money
+		if amount, err = params.ParseAmount(); err != nil {
+			return
+		}
+
+		lineItems, err = convertLineItem(params.CustomerID, amount, bill.Currency, createdAt)
 	}
 
-	amount, err := money.NewFromString(params.Amount, params.Currency)
 	if err != nil {
 		err = errors.BadRequestError("invalid amount or currency")
 		return
 	}
 
-	var processedAmount money.Money
-
-	if amount.Currency != bill.Currency {
-		processedAmount, err = amount.ConvertTo(bill.Currency, config.Rates)
-		if err != nil {
-			err = errors.BadRequestError("invalid amount or currency")
-			return
+	// conversion fees are taxed at the standard rate
+	for i := range lineItems {
+		if lineItems[i].Type != workflow.LineItemTypeFXFee {
+			lineItems[i].TaxCode = params.TaxCode
 		}
-	} else {
-		processedAmount = amount
 	}
 
+	fingerprint, err := params.Fingerprint()
+	if err != nil {
+		return
+	}
+
+	request := workflow.AddLineItemsRequest{
+		IdempotencyKey: params.Key(),
+		Fingerprint:    fingerprint,
+		LineItems:      lineItems,
+	}
+
+	if bill, err = s.updateBill(ctx, billID, workflow.UpdateAddLineItems, request); err != nil {
+		return
+	}
+
+	localize(bill, params.Locale, params.Accept)
+
+	return
+}
+
+// convertLineItem builds the line items for an amount charged in any currency.
+// A conversion markup is either folded into the rate or returned as a separate
+// fee line item, depending on the customer's configured markup mode.
+func convertLineItem(customerID int, amount money.Money, billCurrency money.Currency, createdAt time.Time) (lineItems []workflow.LineItem, err error) {
 	lineItem := workflow.LineItem{
 		ID:        uuid.New().String(),
-		Amount:    processedAmount,
-		CreatedAt: time.Now().UTC(),
+		Type:      workflow.LineItemTypeCharge,
+		Amount:    amount,
+		CreatedAt: createdAt,
+	}
+
+	if amount.Currency == billCurrency {
+		return []workflow.LineItem{lineItem}, nil
+	}
+
+	rate, err := config.Rates.Rate(amount.Currency, billCurrency, createdAt)
+	if err != nil {
+		return
+	}
+
+	lineItem.Conversion = &workflow.Conversion{
+		OriginalAmount:   amount,
+		OriginalCurrency: amount.Currency,
+		Rate:             rate.Value,
+		RateEffectiveAt:  rate.EffectiveAt,
+	}
+
+	markup, hasMarkup := config.FXMarkups.For(amount.Currency, billCurrency)
+	if !hasMarkup {
+		lineItem.Amount, err = amount.Convert(rate)
+		return []workflow.LineItem{lineItem}, err
+	}
+
+	mode := config.FXMarkupMode(customerID)
+
+	converted, err := markup.Convert(amount, rate, mode)
+	if err != nil {
+		return
+	}
+
+	lineItem.Amount = converted.Amount
+
+	if mode == money.MarkupInRate {
+		lineItem.Conversion.Rate = converted.Rate.Value
+		lineItem.Conversion.MidRate = &rate.Value
+		lineItem.Conversion.Markup = &converted.Fee
+
+		return []workflow.LineItem{lineItem}, nil
+	}
+
+	// a markup that rounds to nothing on a small amount is not worth a line
+	if converted.Fee.IsZero() {
+		return []workflow.LineItem{lineItem}, nil
 	}
 
-	err = s.temporalClient.SignalWorkflow(ctx, billID, "", workflow.SignalAddLineItem, lineItem)
+	feeItem := workflow.LineItem{
+		ID:            uuid.New().String(),
+		Type:          workflow.LineItemTypeFXFee,
+		Amount:        converted.Fee,
+		CreatedAt:     createdAt,
+		RelatedItemID: lineItem.ID,
+	}
+
+	return []workflow.LineItem{lineItem, feeItem}, nil
+}
+
+// usageLineItem builds a usage charge. Usage prices are not converted, since a
+// converted sub-cent price would have to be rounded per item.
+func usageLineItem(params *AddLineItemParams, billCurrency money.Currency, createdAt time.Time) (lineItems []workflow.LineItem, err error) {
+	if params.Currency != billCurrency {
+		err = fmt.Errorf("usage must be priced in the bill currency %s", billCurrency)
+		return
+	}
 
+	unitPrice, err := money.NewPreciseFromString(params.UnitPrice, params.Currency)
 	if err != nil {
-		err = errors.SafeInternalError(err, "failed to add line item")
 		return
 	}
 
-	return s.getBill(ctx, billID, params.CustomerID)
+	amount, err := unitPrice.MulInt(params.Quantity).Round()
+	if err != nil {
+		return
+	}
+
+	lineItem := workflow.LineItem{
+		ID:        uuid.New().String(),
+		Type:      workflow.LineItemTypeUsage,
+		Amount:    amount,
+		CreatedAt: createdAt,
+		UnitPrice: &unitPrice,
+		Quantity:  params.Quantity,
+	}
+
+	return []workflow.LineItem{lineItem}, nil
 }
 
 // CloseBill closes a bill so no more items can be added.
 //
 //encore:api public method=POST path=/bills/:billID/close
 func (s *Service) CloseBill(ctx context.Context, billID string, params *CloseBillParams) (bill *workflow.Bill, err error) {
+	// only checks that the bill belongs to the customer,
+	// whether it can still be closed is decided by the workflow
+	if _, err = s.getBill(ctx, billID, params.CustomerID); err != nil {
+		return
+	}
+
+	request := workflow.CloseBillSignal{ClosedAt: time.Now().UTC()}
+
+	if bill, err = s.updateBill(ctx, billID, workflow.UpdateCloseBill, request); err != nil {
+		return
+	}
+
+	localize(bill, params.Locale, params.Accept)
+
+	return
+}
+
+// TransitionBill moves a bill through its lifecycle, e.g. from DRAFT to OPEN,
+// CLOSED to FINALIZED or FINALIZED to PAID. The workflow rejects transitions
+// the bill's current status does not allow.
+//
+//encore:api public method=POST path=/bills/:billID/status
+func (s *Service) TransitionBill(ctx context.Context, billID string, params *TransitionBillParams) (bill *workflow.Bill, err error) {
+	if err = params.Validate(); err != nil {
+		return
+	}
+
+	if _, err = s.getBill(ctx, billID, params.CustomerID); err != nil {
+		return
+	}
+
+	request := workflow.TransitionBillRequest{
+		Status:    params.Status,
+		Reason:    strings.TrimSpace(params.Reason),
+		DueAt:     params.DueAt,
+		ChangedAt: time.Now().UTC(),
+	}
+
+	if bill, err = s.updateBill(ctx, billID, workflow.UpdateTransitionBill, request); err != nil {
+		return
+	}
+
+	localize(bill, params.Locale, params.Accept)
+
+	return
+}
+
+// ApplyPayment records a full or partial payment against a closed bill.
+// Payments in another currency are converted to the bill currency at the
+// rate on the day they were received.
+//
+//encore:api public method=POST path=/bills/:billID/payments
+func (s *Service) ApplyPayment(ctx context.Context, billID string, params *ApplyPaymentParams) (bill *workflow.Bill, err error) {
+	if err = params.Validate(); err != nil {
+		return
+	}
+
 	bill, err = s.getBill(ctx, billID, params.CustomerID)
 	if err != nil {
 		return
 	}
 
-	if bill.Status == workflow.BillStatusClosed {
-		err = errors.BadRequestError("bill is already closed")
+	amount, err := params.ParseAmount()
+	if err != nil {
 		return
 	}
 
-	now := time.Now().UTC()
+	receivedAt := time.Now().UTC()
+	if params.ReceivedAt != nil {
+		receivedAt = params.ReceivedAt.UTC()
+	}
+
+	payment := workflow.Payment{
+		ID:         uuid.New().String(),
+		Amount:     amount,
+		Method:     params.Method,
+		Reference:  strings.TrimSpace(params.Reference),
+		ReceivedAt: receivedAt,
+	}
 
-	signal := workflow.CloseBillSignal{ClosedAt: now}
+	if amount.Currency != bill.Currency {
+		var rate money.Rate
+		if rate, err = config.Rates.Rate(amount.Currency, bill.Currency, receivedAt); err == nil {
+			payment.Amount, err = amount.Convert(rate)
+		}
 
-	err = s.temporalClient.SignalWorkflow(ctx, billID, "", workflow.SignalCloseBill, signal)
+		if err != nil {
+			err = errors.BadRequestError("invalid amount or currency")
+			return
+		}
+
+		payment.Conversion = &workflow.Conversion{
+			OriginalAmount:   amount,
+			OriginalCurrency: amount.Currency,
+			Rate:             rate.Value,
+			RateEffectiveAt:  rate.EffectiveAt,
+		}
+	}
+
+	if bill, err = s.updateBill(ctx, billID, workflow.UpdateApplyPayment, payment); err != nil {
+		return
+	}
+
+	localize(bill, params.Locale, params.Accept)
+
+	return
+}
+
+// IssueCreditNote credits a closed bill, reducing its balance, and emails the
+// credit note to the customer.
+//
+//encore:api public method=POST path=/bills/:billID/credit-notes
+func (s *Service) IssueCreditNote(ctx context.Context, billID string, params *IssueCreditNoteParams) (bill *workflow.Bill, err error) {
+	if err = params.Validate(); err != nil {
+		return
+	}
+
+	bill, err = s.getBill(ctx, billID, params.CustomerID)
+	if err != nil {
+		return
+	}
+
+	issuedAt := time.Now().UTC()
+
+	request := workflow.IssueCreditNoteRequest{
+		ID:       uuid.New().String(),
+		Reason:   strings.TrimSpace(params.Reason),
+		IssuedAt: issuedAt,
+	}
+
+	for _, item := range params.Items {
+		var amount money.Money
+		if amount, err = parseBillAmount(item.Amount, bill.Currency, params.Locale, params.CustomerID); err != nil {
+			return
+		}
+
+		request.LineItems = append(request.LineItems, workflow.LineItem{
+			ID:            uuid.New().String(),
+			Type:          workflow.LineItemTypeCredit,
+			Amount:        amount.Neg(),
+			CreatedAt:     issuedAt,
+			RelatedItemID: item.LineItemID,
+		})
+	}
+
+	if bill, err = s.updateBill(ctx, billID, workflow.UpdateIssueCreditNote, request); err != nil {
+		return
+	}
+
+	localize(bill, params.Locale, params.Accept)
+
+	return
+}
+
+// RecordRefund records money returned to the customer for a bill that was
+// paid more than it is owed, e.g. after a credit note.
+//
+//encore:api public method=POST path=/bills/:billID/refunds
+func (s *Service) RecordRefund(ctx context.Context, billID string, params *RecordRefundParams) (bill *workflow.Bill, err error) {
+	if err = params.Validate(); err != nil {
+		return
+	}
+
+	bill, err = s.getBill(ctx, billID, params.CustomerID)
 	if err != nil {
-		err = errors.SafeInternalError(err, "failed to close bill")
 		return
 	}
 
-	return s.getBill(ctx, billID, params.CustomerID)
+	amount, err := parseBillAmount(params.Amount, bill.Currency, params.Locale, params.CustomerID)
+	if err != nil {
+		return
+	}
+
+	refund := workflow.Refund{
+		ID:         uuid.New().String(),
+		Amount:     amount,
+		Method:     params.Method,
+		Reference:  strings.TrimSpace(params.Reference),
+		Reason:     strings.TrimSpace(params.Reason),
+		RefundedAt: time.Now().UTC(),
+	}
+
+	if bill, err = s.updateBill(ctx, billID, workflow.UpdateRecordRefund, refund); err != nil {
+		return
+	}
+
+	localize(bill, params.Locale, params.Accept)
+
+	return
+}
+
+// updateBill runs a workflow update and returns the bill as the update left it.
+// Updates rejected by the workflow, e.g. on a closed bill, are bad requests.
+func (s *Service) updateBill(ctx context.Context, billID string, updateName string, args ...interface{}) (bill *workflow.Bill, err error) {
+	handle, err := s.temporalClient.UpdateWorkflow(ctx, client.UpdateWorkflowOptions{
+		WorkflowID:   billID,
+		UpdateName:   updateName,
+		Args:         args,
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	})
+
+	if err == nil {
+		err = handle.Get(ctx, &bill)
+	}
+
+	if err != nil {
+		var appErr *temporal.ApplicationError
+		if stderrors.As(err, &appErr) {
+			err = errors.BadRequestError(appErr.Message())
+		} else {
+			err = errors.SafeInternalError(err, "failed to update bill")
+		}
+	}
+
+	return
+}
+
+// VoidLineItem voids a line item on an open bill. The item stays on the bill
+// with the reason and actor, but no longer counts towards the total.
+//
+//encore:api public method=POST path=/bills/:billID/items/:itemID/void
+func (s *Service) VoidLineItem(ctx context.Context, billID string, itemID string, params *VoidLineItemParams) (bill *workflow.Bill, err error) {
+	if err = params.Validate(); err != nil {
+		return
+	}
+
+	if _, err = s.getBill(ctx, billID, params.CustomerID); err != nil {
+		return
+	}
+
+	request := workflow.VoidLineItemRequest{
+		LineItemID: itemID,
+		Reason:     strings.TrimSpace(params.Reason),
+		VoidedBy:   strings.TrimSpace(params.VoidedBy),
+		VoidedAt:   time.Now().UTC(),
+	}
+
+	if bill, err = s.updateBill(ctx, billID, workflow.UpdateVoidLineItem, request); err != nil {
+		return
+	}
+
+	localize(bill, params.Locale, params.Accept)
+
+	return
 }
 
 // GetBill retrieves a bill by ID.
 //
 //encore:api public method=GET path=/bills/:billID
 func (s *Service) GetBill(ctx context.Context, billID string, params *GetBillParams) (*workflow.Bill, error) {
-	return s.getBill(ctx, billID, params.CustomerID)
+	return s.getLocalizedBill(ctx, billID, params.CustomerID, params.Locale, params.Accept)
 }
 
 // getBill is an internal helper to retrieve a bill by ID and customer ID.
@@ -205,10 +561,42 @@ func (s *Service) getBill(ctx context.Context, billID string, customerID int) (b
 	return
 }
 
+// getLocalizedBill retrieves a bill with its amounts formatted for the
+// Accept-Language locale, falling back to the customer's configured locale.
+func (s *Service) getLocalizedBill(ctx context.Context, billID string, customerID int, acceptLanguage string, accept string) (bill *workflow.Bill, err error) {
+	if bill, err = s.getBill(ctx, billID, customerID); err != nil {
+		return
+	}
+
+	localize(bill, acceptLanguage, accept)
+
+	return
+}
+
+// localize formats the bill's amounts in the request's locale, and encodes them
+// in the money format its Accept header asks for.
+func localize(bill *workflow.Bill, acceptLanguage string, accept string) {
+	bill.Localize(requestLocale(acceptLanguage, bill.CustomerID))
+	bill.SetMoneyFormat(money.NegotiateJSONFormat(accept))
+}
+
+// requestLocale prefers the Accept-Language locale over the customer's configured one.
+func requestLocale(acceptLanguage string, customerID int) money.Locale {
+	if locale, ok := money.MatchLocale(acceptLanguage); ok {
+		return locale
+	}
+
+	return config.CustomerLocale(customerID)
+}
+
 // ListBills lists all bills for a customer.
 //
 //encore:api public method=GET path=/bills
 func (s *Service) ListBills(ctx context.Context, params *ListBillsParams) (response *ListBillsResponse, err error) {
+	if err = params.Validate(); err != nil {
+		return
+	}
+
 	query := "WorkflowType = 'BillingPeriodWorkflow'"
 	if params.CustomerID != 0 {
 		query += fmt.Sprintf(" AND CustomerID = %d", params.CustomerID)
@@ -242,8 +630,50 @@ func (s *Service) ListBills(ctx context.Context, params *ListBillsParams) (respo
 			continue
 		}
 
+		localize(bill, params.Locale, params.Accept)
 		response.Bills = append(response.Bills, bill)
 	}
 
 	return response, nil
 }
+
+// ImportRates loads exchange rates from an ECB eurofxref XML or CSV payload
+// into the rate store used for line item conversions.
+//
+//encore:api private method=POST path=/admin/rates/import
+func (s *Service) ImportRates(ctx context.Context, params *ImportRatesParams) (response *ImportRatesResponse, err error) {
+	if err = params.Validate(); err != nil {
+		return
+	}
+
+	table, ok := config.Rates.(*money.RateTable)
+	if !ok {
+		err = errors.SafeInternalError(fmt.Errorf("rate provider %T does not support imports", config.Rates), "failed to import rates")
+		return
+	}
+
+	rates, err := money.ParseRates(params.Format, strings.NewReader(params.Data))
+	if err != nil {
+		err = errors.BadRequestError(err.Error())
+		return
+	}
+
+	changes, err := table.Import(rates, params.Overwrite)
+	if err != nil {
+		err = errors.BadRequestError(err.Error())
+		return
+	}
+
+	if config.RatesFile != "" {
+		if err = table.Save(config.RatesFile); err != nil {
+			err = errors.SafeInternalError(err, "failed to persist rates")
+			return
+		}
+	}
+
+	for _, change := range changes {
+		rlog.Info("imported exchange rate", "change", change.String())
+	}
+
+	return &ImportRatesResponse{Changes: changes}, nil
+}
@@ -0,0 +1,191 @@
+package bill
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"time"
+
+	"encore.dev/rlog"
+	"github.com/google/uuid"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+
+	"github.com/sunneydev/pave-billing-api/bills/config"
+	"github.com/sunneydev/pave-billing-api/bills/errors"
+	"github.com/sunneydev/pave-billing-api/bills/workflow"
+)
+
+// CreateSubscription starts a subscription that opens a new bill for the
+// customer every billing period, each with the optional recurring charge.
+//
+//encore:api public method=POST path=/subscriptions
+func (s *Service) CreateSubscription(ctx context.Context, params *CreateSubscriptionParams) (subscription *workflow.Subscription, err error) {
+	if err = params.Validate(); err != nil {
+		return
+	}
+
+	period, err := params.BillingPeriod()
+	if err != nil {
+		return
+	}
+
+	charge, err := params.RecurringCharge()
+	if err != nil {
+		return
+	}
+
+	subscription = &workflow.Subscription{
+		ID:              uuid.New().String(),
+		CustomerID:      params.CustomerID,
+		Currency:        params.Currency,
+		Period:          period,
+		RecurringCharge: charge,
+		Status:          workflow.SubscriptionStatusActive,
+		CreatedAt:       time.Now().UTC(),
+	}
+
+	_, err = s.temporalClient.ExecuteWorkflow(
+		ctx,
+		client.StartWorkflowOptions{
+			ID:               subscription.ID,
+			TaskQueue:        config.BillingTaskQueue,
+			SearchAttributes: map[string]interface{}{"CustomerID": params.CustomerID},
+		},
+		workflow.SubscriptionWorkflow,
+		*subscription,
+	)
+
+	if err != nil {
+		err = errors.SafeInternalError(err, "failed to start workflow")
+		return
+	}
+
+	return
+}
+
+// GetSubscription retrieves a subscription by ID.
+//
+//encore:api public method=GET path=/subscriptions/:subscriptionID
+func (s *Service) GetSubscription(ctx context.Context, subscriptionID string, params *SubscriptionParams) (*workflow.Subscription, error) {
+	return s.getSubscription(ctx, subscriptionID, params.CustomerID)
+}
+
+// ListSubscriptions lists a customer's subscriptions, including cancelled ones.
+//
+//encore:api public method=GET path=/subscriptions
+func (s *Service) ListSubscriptions(ctx context.Context, params *ListSubscriptionsParams) (response *ListSubscriptionsResponse, err error) {
+	query := fmt.Sprintf("WorkflowType = 'SubscriptionWorkflow' AND CustomerID = %d", params.CustomerID)
+
+	resp, err := s.temporalClient.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+		Query: query,
+	})
+
+	if err != nil {
+		return nil, errors.SafeInternalError(err, "failed to list workflows")
+	}
+
+	response = &ListSubscriptionsResponse{
+		Subscriptions: make([]*workflow.Subscription, 0, len(resp.Executions)),
+	}
+
+	for _, execution := range resp.Executions {
+		subscription, err := s.getSubscription(ctx, execution.Execution.WorkflowId, params.CustomerID)
+		if err != nil {
+			rlog.Error("failed to get subscription",
+				"error", err,
+				"workflow_id", execution.Execution.WorkflowId,
+			)
+
+			continue
+		}
+
+		if params.Status != "" && subscription.Status != workflow.SubscriptionStatus(params.Status) {
+			continue
+		}
+
+		response.Subscriptions = append(response.Subscriptions, subscription)
+	}
+
+	return response, nil
+}
+
+// PauseSubscription stops opening new bills until the subscription is resumed.
+// The current period's bill is still closed and sent as usual.
+//
+//encore:api public method=POST path=/subscriptions/:subscriptionID/pause
+func (s *Service) PauseSubscription(ctx context.Context, subscriptionID string, params *SubscriptionParams) (*workflow.Subscription, error) {
+	return s.updateSubscription(ctx, subscriptionID, params.CustomerID, workflow.UpdatePauseSubscription)
+}
+
+// ResumeSubscription opens a bill for the current period again.
+//
+//encore:api public method=POST path=/subscriptions/:subscriptionID/resume
+func (s *Service) ResumeSubscription(ctx context.Context, subscriptionID string, params *SubscriptionParams) (*workflow.Subscription, error) {
+	return s.updateSubscription(ctx, subscriptionID, params.CustomerID, workflow.UpdateResumeSubscription)
+}
+
+// CancelSubscription stops the subscription after the current period's bill.
+//
+//encore:api public method=POST path=/subscriptions/:subscriptionID/cancel
+func (s *Service) CancelSubscription(ctx context.Context, subscriptionID string, params *SubscriptionParams) (*workflow.Subscription, error) {
+	return s.updateSubscription(ctx, subscriptionID, params.CustomerID, workflow.UpdateCancelSubscription)
+}
+
+func (s *Service) getSubscription(ctx context.Context, subscriptionID string, customerID int) (subscription *workflow.Subscription, err error) {
+	resp, err := s.temporalClient.QueryWorkflow(ctx, subscriptionID, "", workflow.QueryGetSubscription)
+	if err != nil {
+		switch err.(type) {
+		case *serviceerror.NotFound:
+			err = errors.NotFoundError(err, "subscription")
+		default:
+			err = errors.SafeInternalError(err, "failed to query workflow")
+		}
+
+		return
+	}
+
+	if err = resp.Get(&subscription); err != nil {
+		err = errors.SafeInternalError(err, "failed to process subscription")
+		return
+	}
+
+	if subscription.CustomerID != customerID {
+		err = errors.NotFoundError(err, "subscription")
+		return
+	}
+
+	return
+}
+
+// updateSubscription checks the subscription belongs to the customer and runs
+// an update, which the workflow rejects if it is not allowed.
+func (s *Service) updateSubscription(ctx context.Context, subscriptionID string, customerID int, updateName string, args ...interface{}) (subscription *workflow.Subscription, err error) {
+	if _, err = s.getSubscription(ctx, subscriptionID, customerID); err != nil {
+		return
+	}
+
+	handle, err := s.temporalClient.UpdateWorkflow(ctx, client.UpdateWorkflowOptions{
+		WorkflowID:   subscriptionID,
+		UpdateName:   updateName,
+		Args:         args,
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	})
+
+	if err == nil {
+		err = handle.Get(ctx, &subscription)
+	}
+
+	if err != nil {
+		var appErr *temporal.ApplicationError
+		if stderrors.As(err, &appErr) {
+			err = errors.BadRequestError(appErr.Message())
+		} else {
+			err = errors.SafeInternalError(err, "failed to update subscription")
+		}
+	}
+
+	return
+}
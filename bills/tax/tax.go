@@ -0,0 +1,223 @@
+// Package tax computes the taxes charged on a bill from the rates of the
+// customer's jurisdiction and the tax codes of the products billed.
+package tax
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/shopspring/decimal"
+	"github.com/sunneydev/pave-billing-api/bills/money"
+)
+
+// Code is a product tax code. Products without one are taxed at the STANDARD rate.
+type Code string
+
+const (
+	CodeStandard Code = "STANDARD"
+	// CodeExempt products are never taxed.
+	CodeExempt Code = "EXEMPT"
+)
+
+// Rate is a tax charged in a jurisdiction, e.g. "GE" or "US-NY", on products
+// with Code. Inclusive rates are already part of the prices they apply to.
+// A jurisdiction can have several rates for a code, e.g. state and city sales tax.
+type Rate struct {
+	Jurisdiction string          `json:"jurisdiction"`
+	Code         Code            `json:"code"`
+	Name         string          `json:"name"`
+	Percent      decimal.Decimal `json:"percent"`
+	Inclusive    bool            `json:"inclusive,omitempty"`
+}
+
+type Table []Rate
+
+// DefaultRates has Georgian VAT and a few US state sales taxes.
+func DefaultRates() Table {
+	return Table{
+		{Jurisdiction: "GE", Code: CodeStandard, Name: "VAT", Percent: decimal.NewFromInt(18)},
+		{Jurisdiction: "US-CA", Code: CodeStandard, Name: "CA Sales Tax", Percent: decimal.RequireFromString("7.25")},
+		{Jurisdiction: "US-NY", Code: CodeStandard, Name: "NY Sales Tax", Percent: decimal.NewFromInt(4)},
+		{Jurisdiction: "US-TX", Code: CodeStandard, Name: "TX Sales Tax", Percent: decimal.RequireFromString("6.25")},
+	}
+}
+
+// For returns the rates of the jurisdiction for code, or its STANDARD rates
+// when the code has none of its own.
+func (t Table) For(jurisdiction string, code Code) []Rate {
+	if code == "" {
+		code = CodeStandard
+	}
+
+	if code == CodeExempt {
+		return nil
+	}
+
+	var rates, standard []Rate
+	for _, rate := range t {
+		if rate.Jurisdiction != jurisdiction {
+			continue
+		}
+
+		switch rate.Code {
+		case code:
+			rates = append(rates, rate)
+		case CodeStandard:
+			standard = append(standard, rate)
+		}
+	}
+
+	if rates == nil {
+		return standard
+	}
+
+	return rates
+}
+
+// Exemption exempts a customer from tax in the listed jurisdictions, or in all
+// of them when none are listed.
+type Exemption struct {
+	Jurisdictions []string `json:"jurisdictions,omitempty"`
+	Reason        string   `json:"reason"`
+}
+
+func (e *Exemption) covers(jurisdiction string) bool {
+	if e == nil {
+		return false
+	}
+
+	if len(e.Jurisdictions) == 0 {
+		return true
+	}
+
+	for _, exempt := range e.Jurisdictions {
+		if exempt == jurisdiction {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Profile is everything needed to tax a customer's bill. A profile without a
+// jurisdiction charges no tax.
+type Profile struct {
+	Jurisdiction string     `json:"jurisdiction,omitempty"`
+	Rates        []Rate     `json:"rates,omitempty"`
+	Exemption    *Exemption `json:"exemption,omitempty"`
+}
+
+// Line is the taxable amount billed for a tax code.
+type Line struct {
+	Code   Code
+	Amount money.Money
+}
+
+// TaxLine is a tax shown on the bill. Amount is added to the bill total
+// unless the tax is Inclusive, in which case it is already part of Taxable.
+type TaxLine struct {
+	Jurisdiction    string          `json:"jurisdiction"`
+	Name            string          `json:"name"`
+	Code            Code            `json:"code"`
+	Percent         decimal.Decimal `json:"percent"`
+	Inclusive       bool            `json:"inclusive,omitempty"`
+	Taxable         money.Money     `json:"taxable"`
+	Amount          money.Money     `json:"amount"`
+	FormattedAmount string          `json:"formatted_amount,omitempty"`
+}
+
+// Calculate returns a tax line per rate that applies to the lines, each
+// rounded once on the total taxed at that rate, sorted by name, code,
+// percent and then exclusive before inclusive rates.
+func (p Profile) Calculate(lines []Line) ([]TaxLine, error) {
+	if p.Jurisdiction == "" || p.Exemption.covers(p.Jurisdiction) {
+		return nil, nil
+	}
+
+	type rateKey struct {
+		name, code, percent string
+		inclusive           bool
+	}
+
+	table := Table(p.Rates)
+	taxLines := make(map[rateKey]*TaxLine)
+	keys := make([]rateKey, 0)
+
+	for _, line := range lines {
+		for _, rate := range table.For(p.Jurisdiction, line.Code) {
+			key := rateKey{rate.Name, string(rate.Code), rate.Percent.String(), rate.Inclusive}
+
+			taxLine, ok := taxLines[key]
+			if !ok {
+				taxLine = &TaxLine{
+					Jurisdiction: rate.Jurisdiction,
+					Name:         rate.Name,
+					Code:         rate.Code,
+					Percent:      rate.Percent,
+					Inclusive:    rate.Inclusive,
+					Taxable:      money.New(money.ZeroAmount(), line.Amount.Currency),
+				}
+				taxLines[key] = taxLine
+				keys = append(keys, key)
+			}
+
+			var err error
+			if taxLine.Taxable, err = taxLine.Taxable.Add(line.Amount); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	result := make([]TaxLine, 0, len(keys))
+	for _, key := range keys {
+		taxLine := taxLines[key]
+
+		var err error
+		if taxLine.Inclusive {
+			// the tax included in a price p at rate r is p * r / (100 + r)
+			taxLine.Amount, err = taxLine.Taxable.Mul(taxLine.Percent.Div(taxLine.Percent.Add(decimal.NewFromInt(100))))
+		} else {
+			taxLine.Amount, err = taxLine.Taxable.Percent(taxLine.Percent)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute %s: %w", taxLine.Name, err)
+		}
+
+		result = append(result, *taxLine)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+
+		switch {
+		case a.Name != b.Name:
+			return a.Name < b.Name
+		case a.Code != b.Code:
+			return a.Code < b.Code
+		case !a.Percent.Equal(b.Percent):
+			return a.Percent.LessThan(b.Percent)
+		default:
+			return !a.Inclusive && b.Inclusive
+		}
+	})
+
+	return result, nil
+}
+
+// Exclusive sums the tax lines that are added on top of the prices.
+func Exclusive(taxLines []TaxLine, currency money.Currency) (total money.Money, err error) {
+	total = money.New(money.ZeroAmount(), currency)
+
+	for _, taxLine := range taxLines {
+		if taxLine.Inclusive {
+			continue
+		}
+
+		if total, err = total.Add(taxLine.Amount); err != nil {
+			return
+		}
+	}
+
+	return
+}
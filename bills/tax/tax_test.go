@@ -0,0 +1,126 @@
+package tax
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/sunneydev/pave-billing-api/bills/money"
+)
+
+func usd(t *testing.T, amount string) money.Money {
+	m, err := money.NewFromString(amount, money.USD)
+	require.NoError(t, err)
+
+	return m
+}
+
+func Test_Table_For_FallsBackToStandardRates(t *testing.T) {
+	table := Table{
+		{Jurisdiction: "US-NY", Code: CodeStandard, Name: "State", Percent: decimal.NewFromInt(4)},
+		{Jurisdiction: "US-NY", Code: CodeStandard, Name: "City", Percent: decimal.RequireFromString("4.5")},
+		{Jurisdiction: "US-NY", Code: "FOOD", Name: "State", Percent: decimal.Zero},
+	}
+
+	assert.Len(t, table.For("US-NY", ""), 2)
+	assert.Len(t, table.For("US-NY", "SAAS"), 2)
+	assert.Len(t, table.For("US-NY", "FOOD"), 1)
+	assert.Empty(t, table.For("US-NY", CodeExempt))
+	assert.Empty(t, table.For("US-CA", CodeStandard))
+}
+
+func Test_Profile_Calculate_ExclusiveRate(t *testing.T) {
+	profile := Profile{Jurisdiction: "GE", Rates: DefaultRates()}
+
+	lines, err := profile.Calculate([]Line{
+		{Amount: usd(t, "100.00")},
+		{Code: CodeStandard, Amount: usd(t, "0.55")},
+		{Code: CodeExempt, Amount: usd(t, "50.00")},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, lines, 1)
+	assert.Equal(t, "VAT", lines[0].Name)
+	assert.Equal(t, "$100.55", lines[0].Taxable.String())
+	assert.Equal(t, "$18.10", lines[0].Amount.String())
+
+	exclusive, err := Exclusive(lines, money.USD)
+	require.NoError(t, err)
+	assert.Equal(t, "$18.10", exclusive.String())
+}
+
+func Test_Profile_Calculate_InclusiveRate(t *testing.T) {
+	profile := Profile{Jurisdiction: "GE", Rates: []Rate{
+		{Jurisdiction: "GE", Code: CodeStandard, Name: "VAT", Percent: decimal.NewFromInt(18), Inclusive: true},
+	}}
+
+	lines, err := profile.Calculate([]Line{{Amount: usd(t, "118.00")}})
+	require.NoError(t, err)
+
+	require.Len(t, lines, 1)
+	assert.True(t, lines[0].Inclusive)
+	assert.Equal(t, "$18.00", lines[0].Amount.String())
+
+	exclusive, err := Exclusive(lines, money.USD)
+	require.NoError(t, err)
+	assert.True(t, exclusive.IsZero())
+}
+
+func Test_Profile_Calculate_MultipleRatesInJurisdiction(t *testing.T) {
+	profile := Profile{Jurisdiction: "US-NY", Rates: []Rate{
+		{Jurisdiction: "US-NY", Code: CodeStandard, Name: "NY State Sales Tax", Percent: decimal.NewFromInt(4)},
+		{Jurisdiction: "US-NY", Code: CodeStandard, Name: "NYC Sales Tax", Percent: decimal.RequireFromString("4.5")},
+		{Jurisdiction: "GE", Code: CodeStandard, Name: "VAT", Percent: decimal.NewFromInt(18)},
+	}}
+
+	lines, err := profile.Calculate([]Line{{Amount: usd(t, "200.00")}})
+	require.NoError(t, err)
+
+	require.Len(t, lines, 2)
+	assert.Equal(t, "NY State Sales Tax", lines[0].Name)
+	assert.Equal(t, "$8.00", lines[0].Amount.String())
+	assert.Equal(t, "$9.00", lines[1].Amount.String())
+}
+
+func Test_Profile_Calculate_OrdersRatesWithTheSameName(t *testing.T) {
+	profile := Profile{Jurisdiction: "US-NY", Rates: []Rate{
+		{Jurisdiction: "US-NY", Code: "SAAS", Name: "Sales Tax", Percent: decimal.NewFromInt(8), Inclusive: true},
+		{Jurisdiction: "US-NY", Code: "SAAS", Name: "Sales Tax", Percent: decimal.NewFromInt(8)},
+		{Jurisdiction: "US-NY", Code: "SAAS", Name: "Sales Tax", Percent: decimal.NewFromInt(10)},
+		{Jurisdiction: "US-NY", Code: "SAAS", Name: "Sales Tax", Percent: decimal.NewFromInt(4)},
+	}}
+
+	// map iteration order differs between runs, so calculate a few times
+	for i := 0; i < 20; i++ {
+		lines, err := profile.Calculate([]Line{{Code: "SAAS", Amount: usd(t, "100.00")}})
+		require.NoError(t, err)
+
+		require.Len(t, lines, 4)
+		assert.Equal(t, "4", lines[0].Percent.String())
+		assert.Equal(t, "8", lines[1].Percent.String())
+		assert.False(t, lines[1].Inclusive)
+		assert.Equal(t, "8", lines[2].Percent.String())
+		assert.True(t, lines[2].Inclusive)
+		assert.Equal(t, "10", lines[3].Percent.String())
+	}
+}
+
+func Test_Profile_Calculate_Exemptions(t *testing.T) {
+	lines := []Line{{Amount: usd(t, "100.00")}}
+
+	exempt := Profile{Jurisdiction: "GE", Rates: DefaultRates(), Exemption: &Exemption{Reason: "diplomatic mission"}}
+	taxLines, err := exempt.Calculate(lines)
+	require.NoError(t, err)
+	assert.Empty(t, taxLines)
+
+	elsewhere := Profile{Jurisdiction: "GE", Rates: DefaultRates(), Exemption: &Exemption{Jurisdictions: []string{"US-NY"}, Reason: "reseller"}}
+	taxLines, err = elsewhere.Calculate(lines)
+	require.NoError(t, err)
+	assert.Len(t, taxLines, 1)
+
+	untaxed := Profile{Rates: DefaultRates()}
+	taxLines, err = untaxed.Calculate(lines)
+	require.NoError(t, err)
+	assert.Empty(t, taxLines)
+}
@@ -1,51 +1,522 @@
 package bill
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/sunneydev/pave-billing-api/bills/config"
 	"github.com/sunneydev/pave-billing-api/bills/errors"
 	"github.com/sunneydev/pave-billing-api/bills/money"
+	"github.com/sunneydev/pave-billing-api/bills/tax"
 	workflow "github.com/sunneydev/pave-billing-api/bills/workflow"
 )
 
+const maxIdempotencyKeyLength = 255
+
+// CreateBillParams optionally configures the billing period. It defaults to a
+// calendar month in the customer's timezone. PeriodDuration is a Go duration
+// such as "72h", used with the CUSTOM period. Draft bills have to be opened
+// before they can be closed.
 type CreateBillParams struct {
-	CustomerID int            `json:"customer_id"`
-	Currency   money.Currency `json:"currency"`
+	CustomerID     int                      `json:"customer_id"`
+	Currency       money.Currency           `json:"currency"`
+	Period         workflow.BillingInterval `json:"period,omitempty"`
+	PeriodDuration string                   `json:"period_duration,omitempty"`
+	AnchorDay      int                      `json:"anchor_day,omitempty"`
+	Timezone       string                   `json:"timezone,omitempty"`
+	Draft          bool                     `json:"draft,omitempty"`
+	Locale         string                   `header:"Accept-Language"`
+	Accept         string                   `header:"Accept"`
+}
+
+// CreateSubscriptionParams configures the period like CreateBillParams. RecurringAmount
+// is an optional fixed charge added to every bill, e.g. "49.00", in the subscription currency.
+type CreateSubscriptionParams struct {
+	CustomerID      int                      `json:"customer_id"`
+	Currency        money.Currency           `json:"currency"`
+	Period          workflow.BillingInterval `json:"period,omitempty"`
+	PeriodDuration  string                   `json:"period_duration,omitempty"`
+	AnchorDay       int                      `json:"anchor_day,omitempty"`
+	Timezone        string                   `json:"timezone,omitempty"`
+	RecurringAmount string                   `json:"recurring_amount,omitempty"`
+	Locale          string                   `header:"Accept-Language"`
+}
+
+type SubscriptionParams struct {
+	CustomerID int `json:"customer_id" query:"customer_id"`
+}
+
+type ListSubscriptionsParams struct {
+	CustomerID int    `json:"customer_id" query:"customer_id"`
+	Status     string `json:"status" query:"status,omitempty"`
+}
+
+type ListSubscriptionsResponse struct {
+	Subscriptions []*workflow.Subscription `json:"subscriptions"`
 }
 
 type ListBillsParams struct {
 	CustomerID int    `json:"customer_id" query:"customer_id,omitempty"`
 	Status     string `json:"status" query:"status,omitempty"`
+	Locale     string `header:"Accept-Language"`
+	Accept     string `header:"Accept"`
 }
 
+// AddLineItemParams takes either a fixed Amount, or a UnitPrice and Quantity
+// for usage charges priced below the currency's minor unit, e.g. "0.0004" per call.
 type AddLineItemParams struct {
 	CustomerID int            `json:"customer_id"`
-	Amount     string         `json:"amount"`
+	Amount     string         `json:"amount,omitempty"`
+	UnitPrice  string         `json:"unit_price,omitempty"`
+	Quantity   int64          `json:"quantity,omitempty"`
 	Currency   money.Currency `json:"currency"`
+	TaxCode    tax.Code       `json:"tax_code,omitempty"`
+	Locale     string         `header:"Accept-Language"`
+	Accept     string         `header:"Accept"`
+
+	// IdempotencyKey can be sent in the body or as the Idempotency-Key header.
+	IdempotencyKey       string `json:"idempotency_key,omitempty"`
+	IdempotencyKeyHeader string `header:"Idempotency-Key"`
 }
 
 type CloseBillParams struct {
-	CustomerID int `json:"customer_id" query:"customer_id"`
+	CustomerID int    `json:"customer_id" query:"customer_id"`
+	Locale     string `header:"Accept-Language"`
+	Accept     string `header:"Accept"`
+}
+
+// TransitionBillParams moves a bill to Status. DueAt optionally sets the due date
+// when finalizing, e.g. "2025-02-28T00:00:00Z", and defaults to 30 days later.
+type TransitionBillParams struct {
+	CustomerID int                 `json:"customer_id"`
+	Status     workflow.BillStatus `json:"status"`
+	Reason     string              `json:"reason,omitempty"`
+	DueAt      *time.Time          `json:"due_at,omitempty"`
+	Locale     string              `header:"Accept-Language"`
+	Accept     string              `header:"Accept"`
+}
+
+// ApplyPaymentParams records a payment of Amount, e.g. "100.00" or "€90", received
+// in any supported currency. ReceivedAt defaults to now.
+type ApplyPaymentParams struct {
+	CustomerID int                    `json:"customer_id"`
+	Amount     string                 `json:"amount"`
+	Currency   money.Currency         `json:"currency"`
+	Method     workflow.PaymentMethod `json:"method"`
+	Reference  string                 `json:"reference,omitempty"`
+	ReceivedAt *time.Time             `json:"received_at,omitempty"`
+	Locale     string                 `header:"Accept-Language"`
+	Accept     string                 `header:"Accept"`
+}
+
+// IssueCreditNoteParams credits a closed bill. Item amounts are positive and in the
+// bill currency, e.g. "15.00"; they are added to the credit note as negative line items.
+type IssueCreditNoteParams struct {
+	CustomerID int              `json:"customer_id"`
+	Reason     string           `json:"reason"`
+	Items      []CreditNoteItem `json:"items"`
+	Locale     string           `header:"Accept-Language"`
+	Accept     string           `header:"Accept"`
+}
+
+// CreditNoteItem optionally names the bill's line item it credits.
+type CreditNoteItem struct {
+	Amount     string `json:"amount"`
+	LineItemID string `json:"line_item_id,omitempty"`
+}
+
+// RecordRefundParams records Amount, in the bill currency, returned to the customer.
+type RecordRefundParams struct {
+	CustomerID int                    `json:"customer_id"`
+	Amount     string                 `json:"amount"`
+	Method     workflow.PaymentMethod `json:"method"`
+	Reference  string                 `json:"reference,omitempty"`
+	Reason     string                 `json:"reason,omitempty"`
+	Locale     string                 `header:"Accept-Language"`
+	Accept     string                 `header:"Accept"`
+}
+
+// CreateCouponParams takes either PercentOff, e.g. "15", or AmountOff in Currency.
+// A coupon with a CustomerID is attached to that customer: only they can
+// redeem it, and it is applied to the next Periods bills they create.
+type CreateCouponParams struct {
+	Code       string         `json:"code"`
+	PercentOff string         `json:"percent_off,omitempty"`
+	AmountOff  string         `json:"amount_off,omitempty"`
+	Currency   money.Currency `json:"currency,omitempty"`
+	Periods    int            `json:"periods,omitempty"`
+	ExpiresAt  *time.Time     `json:"expires_at,omitempty"`
+	CustomerID int            `json:"customer_id,omitempty"`
+	Locale     string         `header:"Accept-Language"`
+}
+
+type RedeemCouponParams struct {
+	CustomerID int    `json:"customer_id"`
+	Code       string `json:"code"`
+	Locale     string `header:"Accept-Language"`
+	Accept     string `header:"Accept"`
+}
+
+type VoidLineItemParams struct {
+	CustomerID int    `json:"customer_id"`
+	Reason     string `json:"reason"`
+	VoidedBy   string `json:"voided_by"`
+	Locale     string `header:"Accept-Language"`
+	Accept     string `header:"Accept"`
 }
 
 type GetBillParams struct {
-	CustomerID int `json:"customer_id" query:"customer_id"`
+	CustomerID int    `json:"customer_id" query:"customer_id"`
+	Locale     string `header:"Accept-Language"`
+	Accept     string `header:"Accept"`
 }
 
 type ListBillsResponse struct {
 	Bills []*workflow.Bill `json:"bills"`
 }
 
+// ImportRatesParams imports rates in Format. Rates for dates that were already
+// imported are rejected unless Overwrite is set.
+type ImportRatesParams struct {
+	Format    money.RateFormat `json:"format"`
+	Data      string           `json:"data"`
+	Overwrite bool             `json:"overwrite,omitempty"`
+}
+
+type ImportRatesResponse struct {
+	Changes []money.QuoteChange `json:"changes"`
+}
+
 func (p *AddLineItemParams) Validate() (err error) {
-	_, err = money.NewFromString(p.Amount, p.Currency)
+	if p.IdempotencyKey != "" && p.IdempotencyKeyHeader != "" && p.IdempotencyKey != p.IdempotencyKeyHeader {
+		err = errors.BadRequestError("idempotency key in header and body differ")
+		return
+	}
+
+	if len(p.Key()) > maxIdempotencyKeyLength {
+		err = errors.BadRequestError("idempotency key is too long")
+		return
+	}
+
+	if p.UnitPrice == "" {
+		_, err = p.ParseAmount()
+		return
+	}
+
+	if p.Amount != "" {
+		err = errors.BadRequestError("amount cannot be combined with unit_price")
+		return
+	}
+
+	if p.Quantity <= 0 {
+		err = errors.BadRequestError("quantity must be positive")
+		return
+	}
+
+	unitPrice, err := money.NewPreciseFromString(p.UnitPrice, p.Currency)
+	if err != nil || unitPrice.IsNegative() {
+		err = errors.BadRequestError("invalid unit price format")
+	}
+
+	return
+}
+
+// Key returns the idempotency key from the header or the body.
+func (p *AddLineItemParams) Key() string {
+	if p.IdempotencyKeyHeader != "" {
+		return p.IdempotencyKeyHeader
+	}
+
+	return p.IdempotencyKey
+}
+
+// Fingerprint identifies the charge requested by its parsed amount and tax code,
+// so an idempotency key cannot be replayed with a different charge, while
+// "10.0" and "10.00" are the same request.
+func (p *AddLineItemParams) Fingerprint() (fingerprint string, err error) {
+	var charge string
+
+	if p.UnitPrice != "" {
+		var unitPrice money.Precise
+		if unitPrice, err = money.NewPreciseFromString(p.UnitPrice, p.Currency); err != nil {
+			err = errors.BadRequestError("invalid unit price format")
+			return
+		}
+
+		charge = fmt.Sprintf("usage|%s|%s|%d", unitPrice.Currency, unitPrice.Amount().String(), p.Quantity)
+	} else {
+		var amount money.Money
+		if amount, err = p.ParseAmount(); err != nil {
+			return
+		}
+
+		charge = fmt.Sprintf("amount|%s|%d", amount.Currency, amount.MinorUnits())
+	}
+
+	sum := sha256.Sum256([]byte(charge + "|" + string(p.TaxCode)))
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ParseAmount reads Amount as typed by the user, e.g. "1,234.50" or "10 GEL",
+// using the request or customer locale. Currency may be omitted when Amount names it.
+func (p *AddLineItemParams) ParseAmount() (amount money.Money, err error) {
+	amount, err = money.Parse(p.Amount, p.Currency, requestLocale(p.Locale, p.CustomerID))
 	if err != nil {
-		err = errors.BadRequestError("invalid amount format")
+		err = errors.BadRequestError(err.Error())
+	}
+
+	return
+}
+
+func (p *VoidLineItemParams) Validate() (err error) {
+	if strings.TrimSpace(p.Reason) == "" {
+		err = errors.BadRequestError("missing void reason")
+	} else if strings.TrimSpace(p.VoidedBy) == "" {
+		err = errors.BadRequestError("missing voided_by")
+	}
+
+	return
+}
+
+func (p *TransitionBillParams) Validate() (err error) {
+	if !p.Status.IsValid() {
+		err = errors.BadRequestError("invalid bill status")
+	} else if p.DueAt != nil && p.Status != workflow.BillStatusFinalized {
+		err = errors.BadRequestError("due_at can only be set when finalizing")
+	}
+
+	return
+}
+
+func (p *ApplyPaymentParams) Validate() (err error) {
+	if !p.Method.IsValid() {
+		err = errors.BadRequestError("invalid payment method")
+		return
+	}
+
+	if p.ReceivedAt != nil && p.ReceivedAt.After(time.Now()) {
+		err = errors.BadRequestError("received_at is in the future")
+		return
+	}
+
+	amount, err := p.ParseAmount()
+	if err == nil && (amount.IsZero() || amount.IsNegative()) {
+		err = errors.BadRequestError("payment amount must be positive")
+	}
+
+	return
+}
+
+// ParseAmount reads Amount in the request or customer locale, like AddLineItemParams.
+func (p *ApplyPaymentParams) ParseAmount() (amount money.Money, err error) {
+	amount, err = money.Parse(p.Amount, p.Currency, requestLocale(p.Locale, p.CustomerID))
+	if err != nil {
+		err = errors.BadRequestError(err.Error())
+	}
+
+	return
+}
+
+func (p *IssueCreditNoteParams) Validate() (err error) {
+	if strings.TrimSpace(p.Reason) == "" {
+		err = errors.BadRequestError("missing credit note reason")
+	} else if len(p.Items) == 0 {
+		err = errors.BadRequestError("missing credit note items")
+	}
+
+	return
+}
+
+func (p *RecordRefundParams) Validate() (err error) {
+	if !p.Method.IsValid() {
+		err = errors.BadRequestError("invalid refund method")
+	}
+
+	return
+}
+
+// Coupon builds the coupon, rejecting ones that do not take anything off or have already expired.
+func (p *CreateCouponParams) Coupon(now time.Time) (coupon workflow.Coupon, err error) {
+	coupon = workflow.Coupon{
+		Code:       couponCode(p.Code),
+		Periods:    p.Periods,
+		ExpiresAt:  p.ExpiresAt,
+		CustomerID: p.CustomerID,
+		CreatedAt:  now,
+	}
+
+	if p.PercentOff != "" {
+		var percent decimal.Decimal
+		if percent, err = decimal.NewFromString(p.PercentOff); err != nil {
+			err = errors.BadRequestError("invalid percent_off")
+			return
+		}
+
+		coupon.PercentOff = &percent
+	}
+
+	if p.AmountOff != "" {
+		if !p.Currency.IsValid() {
+			err = errors.BadRequestError("invalid currency")
+			return
+		}
+
+		var amount money.Money
+		if amount, err = parseBillAmount(p.AmountOff, p.Currency, p.Locale, p.CustomerID); err != nil {
+			return
+		}
+
+		coupon.AmountOff = &amount
+	}
+
+	if err = coupon.Validate(); err != nil {
+		err = errors.BadRequestError(err.Error())
+		return
+	}
+
+	if coupon.ExpiresAt != nil && !coupon.ExpiresAt.After(now) {
+		err = errors.BadRequestError("expires_at must be in the future")
+	}
+
+	return
+}
+
+func (p *RedeemCouponParams) Validate() (err error) {
+	if couponCode(p.Code) == "" {
+		err = errors.BadRequestError("coupon code is required")
+	}
+
+	return
+}
+
+// couponCode normalizes codes so they are redeemed regardless of case.
+func couponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// parseBillAmount reads a positive amount in the bill currency.
+func parseBillAmount(amount string, currency money.Currency, acceptLanguage string, customerID int) (parsed money.Money, err error) {
+	parsed, err = money.Parse(amount, currency, requestLocale(acceptLanguage, customerID))
+	if err != nil {
+		err = errors.BadRequestError(err.Error())
+	} else if parsed.Currency != currency {
+		err = errors.BadRequestError(fmt.Sprintf("amount must be in the bill currency %s", currency))
+	} else if parsed.IsZero() {
+		err = errors.BadRequestError("amount must be positive")
+	}
+
+	return
+}
+
+func (p *ListBillsParams) Validate() (err error) {
+	if p.Status != "" && !workflow.BillStatus(p.Status).IsValid() {
+		err = errors.BadRequestError("invalid bill status")
 	}
 
 	return
 }
 
 func (p *CreateBillParams) Validate() (err error) {
-	if p.Currency != money.USD && p.Currency != money.GEL {
+	if !p.Currency.IsValid() {
+		err = errors.BadRequestError("invalid currency")
+		return
+	}
+
+	_, err = p.BillingPeriod()
+
+	return
+}
+
+// BillingPeriod builds the bill's period, filling in the customer's timezone when none is given.
+func (p *CreateBillParams) BillingPeriod() (workflow.BillingPeriod, error) {
+	return billingPeriod(p.CustomerID, p.Period, p.AnchorDay, p.Timezone, p.PeriodDuration)
+}
+
+func (p *CreateSubscriptionParams) Validate() (err error) {
+	if p.CustomerID == 0 {
+		err = errors.BadRequestError("missing customer_id")
+		return
+	}
+
+	if !p.Currency.IsValid() {
 		err = errors.BadRequestError("invalid currency")
+		return
+	}
+
+	if _, err = p.BillingPeriod(); err != nil {
+		return
+	}
+
+	_, err = p.RecurringCharge()
+
+	return
+}
+
+// BillingPeriod builds the period of every bill in the subscription.
+func (p *CreateSubscriptionParams) BillingPeriod() (workflow.BillingPeriod, error) {
+	return billingPeriod(p.CustomerID, p.Period, p.AnchorDay, p.Timezone, p.PeriodDuration)
+}
+
+// RecurringCharge parses RecurringAmount in the request or customer locale, nil when none is given.
+func (p *CreateSubscriptionParams) RecurringCharge() (charge *money.Money, err error) {
+	if p.RecurringAmount == "" {
+		return
+	}
+
+	amount, err := money.Parse(p.RecurringAmount, p.Currency, requestLocale(p.Locale, p.CustomerID))
+	if err != nil {
+		err = errors.BadRequestError(err.Error())
+		return
+	}
+
+	if amount.Currency != p.Currency {
+		err = errors.BadRequestError("recurring amount must be in the subscription currency")
+		return
+	}
+
+	if amount.IsZero() {
+		return
+	}
+
+	return &amount, nil
+}
+
+func billingPeriod(customerID int, interval workflow.BillingInterval, anchorDay int, timezone string, duration string) (period workflow.BillingPeriod, err error) {
+	period = workflow.BillingPeriod{
+		Interval:  interval,
+		AnchorDay: anchorDay,
+		Timezone:  timezone,
+	}
+
+	if period.Timezone == "" {
+		period.Timezone = config.CustomerTimezone(customerID)
+	}
+
+	if duration != "" {
+		if period.Duration, err = time.ParseDuration(duration); err != nil {
+			err = errors.BadRequestError("invalid period duration")
+			return
+		}
+	}
+
+	if err = period.Validate(); err != nil {
+		err = errors.BadRequestError(err.Error())
+	}
+
+	return
+}
+
+func (p *ImportRatesParams) Validate() (err error) {
+	if p.Format != money.RateFormatECB && p.Format != money.RateFormatCSV {
+		err = errors.BadRequestError("invalid rate format")
+	} else if p.Data == "" {
+		err = errors.BadRequestError("missing rate data")
 	}
 
 	return
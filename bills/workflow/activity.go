@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/sunneydev/pave-billing-api/bills/config"
 	"go.temporal.io/sdk/activity"
 )
 
@@ -13,6 +14,7 @@ type EmailDetails struct {
 
 func SendBillClosedEmail(ctx context.Context, details EmailDetails) error {
 	logger := activity.GetLogger(ctx)
+	locale := config.CustomerLocale(details.Bill.CustomerID)
 
 	msg := fmt.Sprintf(`
 Dear Customer #%d,
@@ -24,7 +26,25 @@ Total: %s
 		details.Bill.CustomerID,
 		details.Bill.ID,
 		details.Bill.ClosedAt.Format("January 2, 2006"),
-		details.Bill.Total.String())
+		details.Bill.Total.Format(locale))
+
+	for _, discount := range details.Bill.Discounts {
+		msg += fmt.Sprintf("Coupon: %s\n", discount.CouponCode)
+	}
+
+	for _, taxLine := range details.Bill.TaxLines {
+		included := ""
+		if taxLine.Inclusive {
+			included = " (included)"
+		}
+
+		msg += fmt.Sprintf("Tax: %s %s%% on %s: %s%s\n",
+			taxLine.Name,
+			taxLine.Percent.String(),
+			taxLine.Taxable.Format(locale),
+			taxLine.Amount.Format(locale),
+			included)
+	}
 
 	for i := 0; i < len(details.Bill.LineItems); i++ {
 		msg += fmt.Sprintf(`
@@ -34,9 +54,47 @@ Currency: %s
 Created At: %s
 `,
 			i+1,
-			details.Bill.LineItems[i].Amount.String(),
+			details.Bill.LineItems[i].Amount.Format(locale),
 			details.Bill.LineItems[i].Amount.Currency,
 			details.Bill.LineItems[i].CreatedAt.Format("January 2, 2006"))
+
+		if void := details.Bill.LineItems[i].Void; void != nil {
+			msg += fmt.Sprintf("Voided: %s (by %s on %s)\n",
+				void.Reason,
+				void.VoidedBy,
+				void.VoidedAt.Format("January 2, 2006"))
+		}
+
+		if details.Bill.LineItems[i].Type == LineItemTypeFXFee {
+			msg += "Type: Currency conversion fee\n"
+		}
+
+		if details.Bill.LineItems[i].Type == LineItemTypeDiscount {
+			msg += "Type: Discount\n"
+		}
+
+		if unitPrice := details.Bill.LineItems[i].UnitPrice; unitPrice != nil {
+			msg += fmt.Sprintf("Usage: %d x %s = %s\n",
+				details.Bill.LineItems[i].Quantity,
+				unitPrice.Format(locale),
+				details.Bill.LineItems[i].ExactAmount().Format(locale))
+		}
+
+		if conversion := details.Bill.LineItems[i].Conversion; conversion != nil {
+			msg += fmt.Sprintf(`Original Amount: %s (%s)
+Exchange Rate: 1 %s = %s %s (as of %s)
+`,
+				conversion.OriginalAmount.Format(locale),
+				conversion.OriginalCurrency,
+				conversion.OriginalCurrency,
+				conversion.Rate.String(),
+				details.Bill.LineItems[i].Amount.Currency,
+				conversion.RateEffectiveAt.Format("January 2, 2006"))
+
+			if conversion.Markup != nil {
+				msg += fmt.Sprintf("Conversion Fee (included): %s\n", conversion.Markup.Format(locale))
+			}
+		}
 	}
 
 	logger.Info("Sending bill closed email notification",
@@ -48,3 +106,99 @@ Created At: %s
 
 	return nil
 }
+
+type DunningDetails struct {
+	Bill   *Bill
+	Notice DunningNotice
+}
+
+func SendDunningNotice(ctx context.Context, details DunningDetails) error {
+	logger := activity.GetLogger(ctx)
+	locale := config.CustomerLocale(details.Bill.CustomerID)
+
+	subject := fmt.Sprintf("Reminder #%d", details.Notice.Step)
+	if details.Notice.Final {
+		subject = "Final notice"
+	}
+
+	msg := fmt.Sprintf(`
+Dear Customer #%d,
+
+%s: your bill #%s, closed on %s, has not been paid.
+
+Total: %s
+Paid: %s
+Balance Due: %s
+`,
+		details.Bill.CustomerID,
+		subject,
+		details.Bill.ID,
+		details.Bill.ClosedAt.Format("January 2, 2006"),
+		details.Bill.Total.Format(locale),
+		details.Bill.AmountPaid.Format(locale),
+		details.Bill.BalanceDue.Format(locale))
+
+	if details.Bill.DueAt != nil {
+		msg += fmt.Sprintf("Due Date: %s\n", details.Bill.DueAt.Format("January 2, 2006"))
+	}
+
+	logger.Info("Sending dunning notice",
+		"error_type", "EMAIL_SERVICE",
+		"customer_id", details.Bill.CustomerID,
+		"bill_id", details.Bill.ID,
+		"step", details.Notice.Step,
+		"final", details.Notice.Final,
+		"message", msg)
+
+	return nil
+}
+
+type CreditNoteDetails struct {
+	Bill       *Bill
+	CreditNote CreditNote
+}
+
+func SendCreditNoteEmail(ctx context.Context, details CreditNoteDetails) error {
+	logger := activity.GetLogger(ctx)
+	locale := config.CustomerLocale(details.Bill.CustomerID)
+	creditNote := details.CreditNote
+
+	msg := fmt.Sprintf(`
+Dear Customer #%d,
+
+Credit note %s has been issued for your bill #%s on %s.
+
+Reason: %s
+Credited: %s
+Balance Due: %s
+`,
+		details.Bill.CustomerID,
+		creditNote.Number,
+		details.Bill.ID,
+		creditNote.IssuedAt.Format("January 2, 2006"),
+		creditNote.Reason,
+		creditNote.Total.Format(locale),
+		details.Bill.BalanceDue.Format(locale))
+
+	for i, lineItem := range creditNote.LineItems {
+		msg += fmt.Sprintf(`
+Line Item #%d:
+Amount: %s
+`,
+			i+1,
+			lineItem.Amount.Format(locale))
+
+		if lineItem.RelatedItemID != "" {
+			msg += fmt.Sprintf("Credits: %s\n", lineItem.RelatedItemID)
+		}
+	}
+
+	logger.Info("Sending credit note email notification",
+		"error_type", "EMAIL_SERVICE",
+		"customer_id", details.Bill.CustomerID,
+		"bill_id", details.Bill.ID,
+		"credit_note", creditNote.Number,
+		"message", msg)
+
+	return nil
+}
@@ -0,0 +1,327 @@
+package workflow
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sunneydev/pave-billing-api/bills/money"
+	"github.com/sunneydev/pave-billing-api/bills/tax"
+)
+
+func (b *Bill) validateOpen() error {
+	if !b.Status.IsEditable() {
+		return fmt.Errorf("bill is closed")
+	}
+
+	return nil
+}
+
+// validateLineItems rejects the whole batch if any item cannot be added,
+// so a charge and its conversion fee are always added together.
+func (b *Bill) validateLineItems(lineItems []LineItem) error {
+	if err := b.validateOpen(); err != nil {
+		return err
+	}
+
+	if len(lineItems) == 0 {
+		return fmt.Errorf("no line items to add")
+	}
+
+	for _, lineItem := range lineItems {
+		if lineItem.Amount.Currency != b.Currency {
+			return fmt.Errorf("line item currency %s does not match bill currency %s", lineItem.Amount.Currency, b.Currency)
+		}
+	}
+
+	return nil
+}
+
+func (b *Bill) addLineItems(lineItems []LineItem) error {
+	if err := b.validateLineItems(lineItems); err != nil {
+		return err
+	}
+
+	return b.recalculate(append(b.LineItems, lineItems...))
+}
+
+// validateVoid checks that the line item exists and is still counted in the total.
+func (b *Bill) validateVoid(request VoidLineItemRequest) error {
+	if err := b.validateOpen(); err != nil {
+		return err
+	}
+
+	if request.Reason == "" {
+		return fmt.Errorf("void reason is required")
+	}
+
+	for _, lineItem := range b.LineItems {
+		if lineItem.ID == request.LineItemID && lineItem.Void == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("line item %q not found or already voided", request.LineItemID)
+}
+
+// voidLineItem marks the item, and any fees charged for it, as voided.
+// Voided items stay on the bill but no longer count towards the total.
+func (b *Bill) voidLineItem(request VoidLineItemRequest) error {
+	if err := b.validateVoid(request); err != nil {
+		return err
+	}
+
+	void := &LineItemVoid{Reason: request.Reason, VoidedBy: request.VoidedBy, VoidedAt: request.VoidedAt}
+
+	lineItems := make([]LineItem, len(b.LineItems))
+	copy(lineItems, b.LineItems)
+
+	for i, lineItem := range lineItems {
+		if lineItem.Void != nil {
+			continue
+		}
+
+		if lineItem.ID == request.LineItemID || lineItem.RelatedItemID == request.LineItemID {
+			lineItems[i].Void = void
+		}
+	}
+
+	return b.recalculate(lineItems)
+}
+
+// recalculate replaces the line items and recomputes the totals from scratch,
+// leaving the bill unchanged if the new total is out of range.
+func (b *Bill) recalculate(lineItems []LineItem) error {
+	subtotal := money.NewPrecise(money.ZeroAmount(), b.Currency)
+
+	for _, lineItem := range lineItems {
+		if lineItem.Void != nil {
+			continue
+		}
+
+		var err error
+		if subtotal, err = subtotal.Add(lineItem.ExactAmount()); err != nil {
+			return err
+		}
+	}
+
+	total, err := subtotal.Round()
+	if err != nil {
+		return err
+	}
+
+	b.LineItems = lineItems
+	b.Subtotal = subtotal
+	b.Total = total
+
+	return b.updateBalance()
+}
+
+// updateBalance recomputes the amounts paid, credited and refunded, and the
+// balance left to pay. A negative balance is owed back to the customer.
+func (b *Bill) updateBalance() error {
+	zero := money.New(money.ZeroAmount(), b.Currency)
+	paid, credited, refunded := zero, zero, zero
+
+	var err error
+	for _, payment := range b.Payments {
+		if paid, err = paid.Add(payment.Amount); err != nil {
+			return err
+		}
+	}
+
+	for _, creditNote := range b.CreditNotes {
+		if credited, err = credited.Sub(creditNote.Total); err != nil {
+			return err
+		}
+	}
+
+	for _, refund := range b.Refunds {
+		if refunded, err = refunded.Add(refund.Amount); err != nil {
+			return err
+		}
+	}
+
+	if paid, err = paid.Sub(refunded); err != nil {
+		return err
+	}
+
+	balance, err := b.Total.Sub(credited)
+	if err != nil {
+		return err
+	}
+
+	if balance, err = balance.Sub(paid); err != nil {
+		return err
+	}
+
+	b.AmountPaid = paid
+	b.AmountCredited = credited
+	b.AmountRefunded = refunded
+	b.BalanceDue = balance
+
+	return nil
+}
+
+// settle moves an issued bill to PAID once nothing is left to pay, or to
+// PARTIALLY_PAID after a first payment. Overdue bills stay overdue until paid in full.
+func (b *Bill) settle(now time.Time) {
+	switch {
+	case b.BalanceDue.IsZero() || b.BalanceDue.IsNegative():
+		if b.Status.CanTransitionTo(BillStatusPaid) {
+			b.setStatus(BillStatusPaid, now, "")
+		}
+	case !b.AmountPaid.IsZero() && b.Status.CanTransitionTo(BillStatusPartiallyPaid):
+		b.setStatus(BillStatusPartiallyPaid, now, "")
+	}
+}
+
+// close rounds the exact subtotal to the final total, which is the only
+// place usage charges are rounded, then takes off the discounts and adds
+// the taxes due under profile on the discounted amounts. The bill is closed
+// even if a step fails, and the error names the step that failed.
+func (b *Bill) close(closedAt time.Time, profile tax.Profile) (err error) {
+	total, err := b.Subtotal.Round()
+	if err == nil {
+		b.Total = total
+	}
+
+	b.ClosedAt = &closedAt
+	b.setStatus(BillStatusClosed, closedAt, "")
+
+	if err != nil {
+		err = fmt.Errorf("failed to round subtotal: %v", err)
+	} else if err = b.applyDiscounts(closedAt); err != nil {
+		err = fmt.Errorf("failed to apply discounts: %v", err)
+	} else if err = b.applyTax(profile); err != nil {
+		err = fmt.Errorf("failed to compute tax: %v", err)
+	}
+
+	if balanceErr := b.updateBalance(); err == nil {
+		err = balanceErr
+	}
+
+	return
+}
+
+// applyTax computes the tax lines on the line items grouped by tax code,
+// and adds the taxes that are not included in the prices to the total.
+func (b *Bill) applyTax(profile tax.Profile) error {
+	lines, err := b.taxableLines()
+	if err != nil {
+		return err
+	}
+
+	taxLines, err := profile.Calculate(lines)
+	if err != nil {
+		return err
+	}
+
+	exclusive, err := tax.Exclusive(taxLines, b.Currency)
+	if err != nil {
+		return err
+	}
+
+	total, err := b.Total.Add(exclusive)
+	if err != nil {
+		return err
+	}
+
+	b.TaxLines = taxLines
+	b.Tax = exclusive
+	b.Total = total
+
+	return nil
+}
+
+// taxableLines sums the line items that are not voided per tax code, in the
+// order the codes first appear on the bill.
+func (b *Bill) taxableLines() ([]tax.Line, error) {
+	subtotals := make(map[tax.Code]money.Precise)
+	codes := make([]tax.Code, 0)
+
+	for _, lineItem := range b.LineItems {
+		if lineItem.Void != nil {
+			continue
+		}
+
+		subtotal, ok := subtotals[lineItem.TaxCode]
+		if !ok {
+			subtotal = money.NewPrecise(money.ZeroAmount(), b.Currency)
+			codes = append(codes, lineItem.TaxCode)
+		}
+
+		var err error
+		if subtotals[lineItem.TaxCode], err = subtotal.Add(lineItem.ExactAmount()); err != nil {
+			return nil, err
+		}
+	}
+
+	lines := make([]tax.Line, 0, len(codes))
+	for _, code := range codes {
+		amount, err := subtotals[code].Round()
+		if err != nil {
+			return nil, err
+		}
+
+		lines = append(lines, tax.Line{Code: code, Amount: amount})
+	}
+
+	return lines, nil
+}
+
+// validatePayment accepts payments on closed bills that are not yet settled,
+// up to the balance due. A reference can only be used for one payment.
+func (b *Bill) validatePayment(payment Payment) error {
+	if !b.Status.IsOwed() {
+		return fmt.Errorf("payments cannot be applied to a %s bill", b.Status)
+	}
+
+	if payment.Amount.Currency != b.Currency {
+		return fmt.Errorf("payment currency %s does not match bill currency %s", payment.Amount.Currency, b.Currency)
+	}
+
+	if payment.Amount.IsNegative() || payment.Amount.IsZero() {
+		return fmt.Errorf("payment amount must be positive")
+	}
+
+	if !payment.Method.IsValid() {
+		return fmt.Errorf("invalid payment method: %s", payment.Method)
+	}
+
+	if cmp, err := payment.Amount.Cmp(b.BalanceDue); err != nil {
+		return err
+	} else if cmp > 0 {
+		return fmt.Errorf("payment of %s exceeds the balance due of %s", payment.Amount, b.BalanceDue)
+	}
+
+	for _, existing := range b.Payments {
+		if payment.Reference != "" && existing.Reference == payment.Reference {
+			return fmt.Errorf("payment with reference %q was already applied", payment.Reference)
+		}
+	}
+
+	return nil
+}
+
+// applyPayment records the payment and settles the bill. Closed bills are finalized first.
+func (b *Bill) applyPayment(payment Payment, now time.Time) error {
+	if err := b.validatePayment(payment); err != nil {
+		return err
+	}
+
+	b.Payments = append(b.Payments, payment)
+	if err := b.updateBalance(); err != nil {
+		b.Payments = b.Payments[:len(b.Payments)-1]
+		return err
+	}
+
+	if b.Status == BillStatusClosed {
+		if err := b.transition(TransitionBillRequest{Status: BillStatusFinalized, ChangedAt: now, Reason: "payment received"}); err != nil {
+			return err
+		}
+	}
+
+	b.settle(now)
+
+	return nil
+}
@@ -4,9 +4,41 @@ const (
 	SignalAddLineItem      = "add-line-item"
 	SignalCloseBill        = "close-bill"
 	SignalIncrementCounter = "increment"
+	SignalBillClosed       = "bill-closed"
+	SignalApplyDiscount    = "apply-discount"
+)
+
+const (
+	UpdateAddLineItems    = "add-line-items"
+	UpdateCloseBill       = "close-bill"
+	UpdateVoidLineItem    = "void-line-item"
+	UpdateTransitionBill  = "transition-bill"
+	UpdateApplyPayment    = "apply-payment"
+	UpdateIssueCreditNote = "issue-credit-note"
+	UpdateRecordRefund    = "record-refund"
+	UpdateApplyDiscount   = "apply-discount"
+
+	UpdatePauseSubscription    = "pause-subscription"
+	UpdateResumeSubscription   = "resume-subscription"
+	UpdateCancelSubscription   = "cancel-subscription"
+	UpdateDiscountSubscription = "discount-subscription"
+
+	UpdateRedeemCoupon  = "redeem-coupon"
+	UpdateReleaseCoupon = "release-coupon"
 )
 
 const (
 	QueryGetNextID = "get-next-id"
 	QueryGetBill   = "get-bill"
+
+	QueryGetSubscription = "get-subscription"
+	QueryGetCoupon       = "get-coupon"
+)
+
+// change IDs passed to workflow.GetVersion, so executions started on older code replay as they ran
+const (
+	ChangeAwaitPeriodEnd  = "await-period-end"
+	ChangeAwaitSettlement = "await-settlement"
+	ChangeDunning         = "dunning"
+	ChangeTaxAtClose      = "tax-at-close"
 )
@@ -0,0 +1,112 @@
+package workflow
+
+import (
+	"fmt"
+
+	"github.com/sunneydev/pave-billing-api/bills/money"
+	"go.temporal.io/sdk/workflow"
+)
+
+// RedeemCouponRequest redeems a coupon for a bill or subscription in Currency.
+type RedeemCouponRequest struct {
+	Redemption Redemption     `json:"redemption"`
+	Currency   money.Currency `json:"currency"`
+}
+
+// couponUpdatesPerRun bounds the history of a coupon run, after which it continues as new.
+var couponUpdatesPerRun = 500
+
+// maxCouponRedemptions bounds the redemptions a coupon keeps, and carries when it continues as new.
+var maxCouponRedemptions = 100
+
+// CouponWorkflow holds a coupon and its redemptions until it expires.
+// Coupons without an expiry stay redeemable until the workflow is terminated,
+// continuing as new once their history grows long.
+func CouponWorkflow(ctx workflow.Context, coupon Coupon) error {
+	logger := workflow.GetLogger(ctx)
+	updates := 0
+
+	if err := coupon.Validate(); err != nil {
+		return fmt.Errorf("invalid coupon: %v", err)
+	}
+
+	err := workflow.SetQueryHandler(ctx, QueryGetCoupon, func() (*Coupon, error) {
+		return &coupon, nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to register query handler: %v", err)
+	}
+
+	err = workflow.SetUpdateHandlerWithOptions(ctx, UpdateRedeemCoupon,
+		func(ctx workflow.Context, request RedeemCouponRequest) (*Discount, error) {
+			now := workflow.Now(ctx).UTC()
+			if err := coupon.validateRedemption(request.Redemption, request.Currency, now); err != nil {
+				return nil, err
+			}
+
+			updates++
+			request.Redemption.RedeemedAt = now
+			coupon.redeem(request.Redemption)
+
+			logger.Info("redeemed coupon", "code", coupon.Code, "customer_id", request.Redemption.CustomerID)
+
+			discount := coupon.discount(now)
+
+			return &discount, nil
+		},
+		workflow.UpdateHandlerOptions{Validator: func(request RedeemCouponRequest) error {
+			return coupon.validateRedemption(request.Redemption, request.Currency, workflow.Now(ctx).UTC())
+		}},
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to register update handler: %v", err)
+	}
+
+	err = workflow.SetUpdateHandlerWithOptions(ctx, UpdateReleaseCoupon,
+		func(ctx workflow.Context, redemption Redemption) (*Coupon, error) {
+			if err := coupon.release(redemption); err != nil {
+				return nil, err
+			}
+
+			updates++
+
+			logger.Info("released coupon", "code", coupon.Code, "customer_id", redemption.CustomerID)
+
+			return &coupon, nil
+		},
+		workflow.UpdateHandlerOptions{Validator: coupon.validateRelease},
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to register update handler: %v", err)
+	}
+
+	var expiry workflow.Future
+	if coupon.ExpiresAt != nil {
+		expiry = workflow.NewTimer(ctx, max(coupon.ExpiresAt.Sub(workflow.Now(ctx)), 0))
+	}
+
+	expired := func() bool { return expiry != nil && expiry.IsReady() }
+
+	err = workflow.Await(ctx, func() bool {
+		return expired() || updates >= couponUpdatesPerRun || workflow.GetInfo(ctx).GetContinueAsNewSuggested()
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err = workflow.Await(ctx, func() bool { return workflow.AllHandlersFinished(ctx) }); err != nil {
+		return err
+	}
+
+	if !expired() {
+		return workflow.NewContinueAsNewError(ctx, CouponWorkflow, coupon)
+	}
+
+	logger.Info("coupon expired", "code", coupon.Code)
+
+	return nil
+}
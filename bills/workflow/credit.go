@@ -0,0 +1,162 @@
+package workflow
+
+import (
+	"fmt"
+
+	"github.com/sunneydev/pave-billing-api/bills/money"
+)
+
+// validateCreditNote checks that the credit note only has negative line items in
+// the bill currency, and that the bill is not credited for more than its total.
+func (b *Bill) validateCreditNote(request IssueCreditNoteRequest) error {
+	switch b.Status {
+	case BillStatusClosed, BillStatusFinalized, BillStatusPartiallyPaid, BillStatusOverdue, BillStatusPaid:
+	default:
+		return fmt.Errorf("credit notes cannot be issued for a %s bill", b.Status)
+	}
+
+	if request.Reason == "" {
+		return fmt.Errorf("credit note reason is required")
+	}
+
+	if len(request.LineItems) == 0 {
+		return fmt.Errorf("no line items to credit")
+	}
+
+	total, err := creditNoteTotal(request.LineItems, b.Currency)
+	if err != nil {
+		return err
+	}
+
+	for _, lineItem := range request.LineItems {
+		if lineItem.RelatedItemID != "" && !b.hasLineItem(lineItem.RelatedItemID) {
+			return fmt.Errorf("line item %q not found", lineItem.RelatedItemID)
+		}
+	}
+
+	credited, err := b.AmountCredited.Sub(total)
+	if err != nil {
+		return err
+	}
+
+	if cmp, err := credited.Cmp(b.Total); err != nil {
+		return err
+	} else if cmp > 0 {
+		return fmt.Errorf("credits of %s would exceed the bill total of %s", credited, b.Total)
+	}
+
+	return nil
+}
+
+// issueCreditNote adds the next numbered credit note and settles the bill,
+// which is paid once credits and payments cover its total. A closed bill
+// that is credited in full is finalized first, as it is for payments.
+func (b *Bill) issueCreditNote(request IssueCreditNoteRequest) (*CreditNote, error) {
+	if err := b.validateCreditNote(request); err != nil {
+		return nil, err
+	}
+
+	total, err := creditNoteTotal(request.LineItems, b.Currency)
+	if err != nil {
+		return nil, err
+	}
+
+	creditNote := CreditNote{
+		ID:        request.ID,
+		Number:    fmt.Sprintf("CN-%s-%03d", b.ID, len(b.CreditNotes)+1),
+		BillID:    b.ID,
+		Reason:    request.Reason,
+		LineItems: request.LineItems,
+		Total:     total,
+		IssuedAt:  request.IssuedAt,
+	}
+
+	b.CreditNotes = append(b.CreditNotes, creditNote)
+	if err := b.updateBalance(); err != nil {
+		b.CreditNotes = b.CreditNotes[:len(b.CreditNotes)-1]
+		return nil, err
+	}
+
+	if b.Status == BillStatusClosed && !b.BalanceDue.IsPositive() {
+		if err := b.transition(TransitionBillRequest{Status: BillStatusFinalized, ChangedAt: request.IssuedAt, Reason: "credited in full"}); err != nil {
+			return nil, err
+		}
+	}
+
+	b.settle(request.IssuedAt)
+
+	return &b.CreditNotes[len(b.CreditNotes)-1], nil
+}
+
+func creditNoteTotal(lineItems []LineItem, currency money.Currency) (total money.Money, err error) {
+	total = money.New(money.ZeroAmount(), currency)
+
+	for _, lineItem := range lineItems {
+		if lineItem.Amount.Currency != currency {
+			return total, fmt.Errorf("line item currency %s does not match bill currency %s", lineItem.Amount.Currency, currency)
+		}
+
+		if !lineItem.Amount.IsNegative() {
+			return total, fmt.Errorf("credit note line items must be negative")
+		}
+
+		if total, err = total.Add(lineItem.Amount); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (b *Bill) hasLineItem(id string) bool {
+	for _, lineItem := range b.LineItems {
+		if lineItem.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validateRefund only allows refunding what is owed back to the customer,
+// i.e. payments in excess of the credited total.
+func (b *Bill) validateRefund(refund Refund) error {
+	if refund.Amount.Currency != b.Currency {
+		return fmt.Errorf("refund currency %s does not match bill currency %s", refund.Amount.Currency, b.Currency)
+	}
+
+	if refund.Amount.IsNegative() || refund.Amount.IsZero() {
+		return fmt.Errorf("refund amount must be positive")
+	}
+
+	if !refund.Method.IsValid() {
+		return fmt.Errorf("invalid refund method: %s", refund.Method)
+	}
+
+	owed := b.BalanceDue.Neg()
+	if owed.IsZero() || owed.IsNegative() {
+		return fmt.Errorf("nothing is owed to the customer")
+	}
+
+	if cmp, err := refund.Amount.Cmp(owed); err != nil {
+		return err
+	} else if cmp > 0 {
+		return fmt.Errorf("refund of %s exceeds the %s owed to the customer", refund.Amount, owed)
+	}
+
+	return nil
+}
+
+func (b *Bill) recordRefund(refund Refund) error {
+	if err := b.validateRefund(refund); err != nil {
+		return err
+	}
+
+	b.Refunds = append(b.Refunds, refund)
+	if err := b.updateBalance(); err != nil {
+		b.Refunds = b.Refunds[:len(b.Refunds)-1]
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package workflow
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/sunneydev/pave-billing-api/bills/config"
+	"github.com/sunneydev/pave-billing-api/bills/money"
+)
+
+// creditNoteRequest builds a credit note for amount in USD, issued when it is sent.
+func creditNoteRequest(id string, amount string) func(time.Time) interface{} {
+	credit, _ := money.NewSignedFromString("-"+amount, money.USD)
+
+	return func(now time.Time) interface{} {
+		return IssueCreditNoteRequest{
+			ID:        id,
+			Reason:    "overcharged",
+			LineItems: []LineItem{{ID: id + "-item", Type: LineItemTypeCredit, Amount: credit, CreatedAt: now}},
+			IssuedAt:  now,
+		}
+	}
+}
+
+// refundRequest builds a card refund of amount in USD, made when it is sent.
+func refundRequest(id string, amount string) func(time.Time) interface{} {
+	refunded, _ := money.NewFromString(amount, money.USD)
+
+	return func(now time.Time) interface{} {
+		return Refund{ID: id, Amount: refunded, Method: PaymentMethodCard, RefundedAt: now}
+	}
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_CreditNoteAndRefundAfterPayment() {
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	s.env.SetStartTime(start)
+
+	var emails []CreditNote
+	s.env.OnActivity(SendCreditNoteEmail, mock.Anything, mock.Anything).Return(func(ctx context.Context, details CreditNoteDetails) error {
+		emails = append(emails, details.CreditNote)
+		return nil
+	})
+
+	partial, _ := money.NewFromString("90.00", money.USD)
+
+	var credited, refunded *Bill
+	var rejection error
+
+	s.addLineItem("100.00", time.Second)
+	s.update(UpdateTransitionBill, "close", time.Second*2, transitionTo(BillStatusClosed), nil, nil)
+	s.update(UpdateApplyPayment, "p1", time.Second*3, constant(Payment{ID: "p1", Amount: partial, Method: PaymentMethodCard}), nil, &rejection)
+	s.update(UpdateIssueCreditNote, "cn1", time.Second*4, creditNoteRequest("cn1", "15.00"), nil, &rejection)
+	s.update(UpdateIssueCreditNote, "cn2", time.Second*5, creditNoteRequest("cn2", "5.00"), &credited, &rejection)
+	s.update(UpdateRecordRefund, "r1", time.Second*6, refundRequest("r1", "10.00"), &refunded, &rejection)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.NoError(rejection)
+
+	s.Require().NotNil(credited)
+	s.Equal(BillStatusPaid, credited.Status)
+	s.Equal("$20.00", credited.AmountCredited.String())
+	s.Equal("-$10.00", credited.BalanceDue.String())
+	s.Require().Len(credited.CreditNotes, 2)
+	s.Equal("CN-bill-123-001", credited.CreditNotes[0].Number)
+	s.Equal("CN-bill-123-002", credited.CreditNotes[1].Number)
+	s.Equal("-$5.00", credited.CreditNotes[1].Total.String())
+
+	s.Require().NotNil(refunded)
+	s.Equal("$80.00", refunded.AmountPaid.String())
+	s.Equal("$10.00", refunded.AmountRefunded.String())
+	s.True(refunded.BalanceDue.IsZero())
+
+	s.Require().Len(emails, 2)
+	s.Equal("CN-bill-123-001", emails[0].Number)
+
+	// the workflow ends once the refund settles the bill, not after the retention
+	s.True(s.env.Now().Before(start.Add(config.BillRetention)))
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_CreditNoteSettlesUnpaidBill() {
+	var credited *Bill
+	var rejection error
+
+	s.addLineItem("30.00", time.Second)
+	s.update(UpdateTransitionBill, "close", time.Second*2, transitionTo(BillStatusClosed), nil, nil)
+	s.update(UpdateTransitionBill, "finalize", time.Second*3, transitionTo(BillStatusFinalized), nil, nil)
+	s.update(UpdateIssueCreditNote, "cn1", time.Second*4, creditNoteRequest("cn1", "30.00"), &credited, &rejection)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.NoError(s.env.GetWorkflowError())
+	s.NoError(rejection)
+	s.Require().NotNil(credited)
+	s.Equal(BillStatusPaid, credited.Status)
+	s.True(credited.BalanceDue.IsZero())
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_FullCreditFinalizesClosedBill() {
+	var credited *Bill
+	var rejection error
+
+	s.addLineItem("30.00", time.Second)
+	s.update(UpdateTransitionBill, "close", time.Second*2, transitionTo(BillStatusClosed), nil, nil)
+	s.update(UpdateIssueCreditNote, "cn1", time.Second*3, creditNoteRequest("cn1", "30.00"), &credited, &rejection)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.NoError(s.env.GetWorkflowError())
+	s.NoError(rejection)
+	s.Require().NotNil(credited)
+	s.Equal(BillStatusPaid, credited.Status)
+	s.NotNil(credited.FinalizedAt)
+	s.Equal("credited in full", credited.StatusHistory[len(credited.StatusHistory)-2].Reason)
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_RejectsInvalidCreditsAndRefunds() {
+	var onOpenBill, overCredited, overRefunded error
+
+	s.addLineItem("10.00", time.Second)
+	s.update(UpdateIssueCreditNote, "open", time.Second*2, creditNoteRequest("open", "1.00"), nil, &onOpenBill)
+	s.update(UpdateTransitionBill, "close", time.Second*3, transitionTo(BillStatusClosed), nil, nil)
+	s.update(UpdateIssueCreditNote, "too-much", time.Second*4, creditNoteRequest("too-much", "10.01"), nil, &overCredited)
+	s.update(UpdateRecordRefund, "nothing-owed", time.Second*5, refundRequest("nothing-owed", "1.00"), nil, &overRefunded)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.NoError(s.env.GetWorkflowError())
+	s.ErrorContains(onOpenBill, "credit notes cannot be issued for a OPEN bill")
+	s.ErrorContains(overCredited, "would exceed the bill total")
+	s.ErrorContains(overRefunded, "nothing is owed to the customer")
+}
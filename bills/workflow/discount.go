@@ -0,0 +1,241 @@
+package workflow
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/sunneydev/pave-billing-api/bills/money"
+)
+
+var hundred = decimal.NewFromInt(100)
+
+// Validate checks that the coupon takes off exactly one of a percentage or an amount.
+func (c *Coupon) Validate() error {
+	if c.Code == "" {
+		return fmt.Errorf("coupon code is required")
+	}
+
+	if (c.PercentOff == nil) == (c.AmountOff == nil) {
+		return fmt.Errorf("coupon must have either percent_off or amount_off")
+	}
+
+	if c.PercentOff != nil && (!c.PercentOff.IsPositive() || c.PercentOff.GreaterThan(hundred)) {
+		return fmt.Errorf("percent_off must be between 0 and 100")
+	}
+
+	if c.AmountOff != nil && !c.AmountOff.IsPositive() {
+		return fmt.Errorf("amount_off must be positive")
+	}
+
+	if c.Periods < 0 {
+		return fmt.Errorf("periods cannot be negative")
+	}
+
+	return nil
+}
+
+// validateRedemption checks the coupon has not expired, belongs to the
+// customer if it is restricted, and that a fixed amount is in the bill currency.
+func (c *Coupon) validateRedemption(redemption Redemption, currency money.Currency, now time.Time) error {
+	if c.ExpiresAt != nil && !now.Before(*c.ExpiresAt) {
+		return fmt.Errorf("coupon %s has expired", c.Code)
+	}
+
+	if c.CustomerID != 0 && c.CustomerID != redemption.CustomerID {
+		return fmt.Errorf("coupon %s is not valid for this customer", c.Code)
+	}
+
+	if c.AmountOff != nil && c.AmountOff.Currency != currency {
+		return fmt.Errorf("coupon %s is for %s, not %s", c.Code, c.AmountOff.Currency, currency)
+	}
+
+	if c.CustomerID != 0 && c.Periods > 0 && redemption.BillID != "" && c.BillsRedeemed >= c.Periods {
+		return fmt.Errorf("coupon %s was already used for %d bills", c.Code, c.Periods)
+	}
+
+	return nil
+}
+
+// redeem records the redemption, keeping only the latest maxCouponRedemptions
+// of them so the coupon stays small however often it is used.
+func (c *Coupon) redeem(redemption Redemption) {
+	c.Redeemed++
+	if redemption.BillID != "" {
+		c.BillsRedeemed++
+	}
+
+	c.Redemptions = append(c.Redemptions, redemption)
+	if len(c.Redemptions) > maxCouponRedemptions {
+		c.Redemptions = append([]Redemption(nil), c.Redemptions[len(c.Redemptions)-maxCouponRedemptions:]...)
+	}
+}
+
+// validateRelease checks the redemption was recorded, so it can be released
+// when the discount could not be applied to the bill or subscription.
+func (c *Coupon) validateRelease(redemption Redemption) error {
+	if c.redemption(redemption) < 0 {
+		return fmt.Errorf("coupon %s was not redeemed for this bill or subscription", c.Code)
+	}
+
+	return nil
+}
+
+func (c *Coupon) release(redemption Redemption) error {
+	if err := c.validateRelease(redemption); err != nil {
+		return err
+	}
+
+	i := c.redemption(redemption)
+	c.Redemptions = append(c.Redemptions[:i], c.Redemptions[i+1:]...)
+
+	c.Redeemed--
+	if redemption.BillID != "" {
+		c.BillsRedeemed--
+	}
+
+	return nil
+}
+
+// redemption returns the index of the latest matching redemption, or -1.
+func (c *Coupon) redemption(redemption Redemption) int {
+	for i := len(c.Redemptions) - 1; i >= 0; i-- {
+		r := c.Redemptions[i]
+		if r.CustomerID == redemption.CustomerID && r.BillID == redemption.BillID && r.SubscriptionID == redemption.SubscriptionID {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (c *Coupon) discount(appliedAt time.Time) Discount {
+	return Discount{
+		CouponCode: c.Code,
+		PercentOff: c.PercentOff,
+		AmountOff:  c.AmountOff,
+		Periods:    c.Periods,
+		AppliedAt:  appliedAt,
+	}
+}
+
+func (b *Bill) validateDiscount(discount Discount) error {
+	if err := b.validateOpen(); err != nil {
+		return err
+	}
+
+	if discount.AmountOff != nil && discount.AmountOff.Currency != b.Currency {
+		return fmt.Errorf("discount currency %s does not match bill currency %s", discount.AmountOff.Currency, b.Currency)
+	}
+
+	if b.HasCoupon(discount.CouponCode) {
+		return fmt.Errorf("coupon %s was already applied", discount.CouponCode)
+	}
+
+	return nil
+}
+
+// HasCoupon reports whether the coupon was already applied to the bill.
+func (b *Bill) HasCoupon(code string) bool {
+	for _, existing := range b.Discounts {
+		if existing.CouponCode == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (b *Bill) addDiscount(discount Discount) error {
+	if err := b.validateDiscount(discount); err != nil {
+		return err
+	}
+
+	b.Discounts = append(b.Discounts, discount)
+
+	return nil
+}
+
+// applyDiscounts adds a negative DISCOUNT line item per discount and tax code,
+// splitting each discount across the tax codes in proportion to their amounts,
+// so taxes are charged on the discounted prices. Discounts never take the bill
+// below zero.
+func (b *Bill) applyDiscounts(appliedAt time.Time) error {
+	if len(b.Discounts) == 0 {
+		return nil
+	}
+
+	lines, err := b.taxableLines()
+	if err != nil {
+		return err
+	}
+
+	base := money.New(money.ZeroAmount(), b.Currency)
+	ratios := make([]int, len(lines))
+
+	for i, line := range lines {
+		if !line.Amount.IsPositive() {
+			continue
+		}
+
+		if base, err = base.Add(line.Amount); err != nil {
+			return err
+		}
+
+		ratios[i] = int(line.Amount.MinorUnits())
+	}
+
+	if !base.IsPositive() {
+		return nil
+	}
+
+	remaining := base
+	lineItems := b.LineItems
+
+	for _, discount := range b.Discounts {
+		if !remaining.IsPositive() {
+			break
+		}
+
+		amount := money.New(money.ZeroAmount(), b.Currency)
+		switch {
+		case discount.PercentOff != nil:
+			if amount, err = base.Percent(*discount.PercentOff); err != nil {
+				return err
+			}
+		case discount.AmountOff != nil:
+			amount = *discount.AmountOff
+		}
+
+		if cmp, err := amount.Cmp(remaining); err != nil {
+			return err
+		} else if cmp > 0 {
+			amount = remaining
+		}
+
+		parts, err := amount.Allocate(ratios...)
+		if err != nil {
+			return err
+		}
+
+		for i, part := range parts {
+			if part.IsZero() {
+				continue
+			}
+
+			lineItems = append(lineItems, LineItem{
+				ID:        fmt.Sprintf("discount-%s-%d", discount.CouponCode, i+1),
+				Type:      LineItemTypeDiscount,
+				Amount:    part.Neg(),
+				CreatedAt: appliedAt,
+				TaxCode:   lines[i].Code,
+			})
+		}
+
+		if remaining, err = remaining.Sub(amount); err != nil {
+			return err
+		}
+	}
+
+	return b.recalculate(lineItems)
+}
@@ -0,0 +1,351 @@
+package workflow
+
+import (
+	"context"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/mock"
+	"github.com/sunneydev/pave-billing-api/bills/config"
+	"github.com/sunneydev/pave-billing-api/bills/money"
+	"github.com/sunneydev/pave-billing-api/bills/tax"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_AppliesDiscountsBeforeTax() {
+	config.CustomerJurisdictions[789] = "GE"
+	defer delete(config.CustomerJurisdictions, 789)
+
+	service, _ := money.NewFromString("100.00", money.USD)
+	book, _ := money.NewFromString("20.00", money.USD)
+	percent := decimal.NewFromInt(10)
+	fixed, _ := money.NewFromString("6.00", money.USD)
+
+	var rejection, duplicate error
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(UpdateAddLineItems, "add", &testsuite.TestUpdateCallback{
+			OnAccept:   func() {},
+			OnReject:   func(err error) { s.Fail("update should not be rejected", err) },
+			OnComplete: func(interface{}, error) {},
+		}, AddLineItemsRequest{LineItems: []LineItem{
+			{ID: "service", Amount: service, CreatedAt: time.Now().UTC()},
+			{ID: "book", Amount: book, CreatedAt: time.Now().UTC(), TaxCode: tax.CodeExempt},
+		}})
+	}, time.Second)
+
+	s.update(UpdateApplyDiscount, "d1", time.Second*2, constant(Discount{CouponCode: "SAVE10", PercentOff: &percent}), nil, &rejection)
+	s.update(UpdateApplyDiscount, "d2", time.Second*3, constant(Discount{CouponCode: "SIX", AmountOff: &fixed}), nil, &rejection)
+	s.update(UpdateApplyDiscount, "d3", time.Second*4, constant(Discount{CouponCode: "SIX", AmountOff: &fixed}), nil, &duplicate)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 789, money.USD, BillingPeriod{}, false)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.NoError(rejection)
+	s.EqualError(duplicate, "coupon SIX was already applied")
+
+	var bill *Bill
+	result, err := s.env.QueryWorkflow(QueryGetBill)
+	s.NoError(err)
+	s.NoError(result.Get(&bill))
+
+	s.Len(bill.Discounts, 2)
+
+	var discounts []string
+	for _, lineItem := range bill.LineItems {
+		if lineItem.Type == LineItemTypeDiscount {
+			discounts = append(discounts, lineItem.ID+" "+lineItem.Amount.String())
+		}
+	}
+
+	s.Equal([]string{
+		"discount-SAVE10-1 -$10.00",
+		"discount-SAVE10-2 -$2.00",
+		"discount-SIX-1 -$5.00",
+		"discount-SIX-2 -$1.00",
+	}, discounts)
+
+	s.Require().Len(bill.TaxLines, 1)
+	s.Equal("$85.00", bill.TaxLines[0].Taxable.String())
+	s.Equal("$15.30", bill.Tax.String())
+	s.Equal("$102.00", bill.Subtotal.String())
+	s.Equal("$117.30", bill.Total.String())
+}
+
+func (s *BillingWorkflowTestSuite) Test_Bill_DiscountsNeverGoBelowZero() {
+	charge, _ := money.NewFromString("30.00", money.USD)
+	first, _ := money.NewFromString("25.00", money.USD)
+	second, _ := money.NewFromString("25.00", money.USD)
+	closedAt := time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)
+
+	bill := &Bill{ID: "bill-123", Currency: money.USD, Status: BillStatusOpen}
+	s.Require().NoError(bill.recalculate([]LineItem{{ID: "charge", Amount: charge}}))
+	s.Require().NoError(bill.addDiscount(Discount{CouponCode: "FIRST", AmountOff: &first}))
+	s.Require().NoError(bill.addDiscount(Discount{CouponCode: "SECOND", AmountOff: &second}))
+
+	gel, _ := money.NewFromString("5.00", money.GEL)
+	s.EqualError(bill.addDiscount(Discount{CouponCode: "GEL", AmountOff: &gel}), "discount currency GEL does not match bill currency USD")
+
+	s.Require().NoError(bill.close(closedAt, tax.Profile{}))
+
+	s.Require().Len(bill.LineItems, 3)
+	s.Equal("-$25.00", bill.LineItems[1].Amount.String())
+	s.Equal("-$5.00", bill.LineItems[2].Amount.String())
+	s.True(bill.Total.IsZero())
+	s.True(bill.BalanceDue.IsZero())
+
+	s.EqualError(bill.addDiscount(Discount{CouponCode: "LATE", AmountOff: &first}), "bill is closed")
+}
+
+func (s *BillingWorkflowTestSuite) Test_Coupon_Validate() {
+	percent := decimal.NewFromInt(15)
+	tooMuch := decimal.NewFromInt(150)
+	amount, _ := money.NewFromString("10.00", money.USD)
+
+	s.NoError((&Coupon{Code: "SAVE15", PercentOff: &percent}).Validate())
+	s.NoError((&Coupon{Code: "TENOFF", AmountOff: &amount, Periods: 3}).Validate())
+
+	s.EqualError((&Coupon{PercentOff: &percent}).Validate(), "coupon code is required")
+	s.EqualError((&Coupon{Code: "BOTH", PercentOff: &percent, AmountOff: &amount}).Validate(), "coupon must have either percent_off or amount_off")
+	s.EqualError((&Coupon{Code: "NONE"}).Validate(), "coupon must have either percent_off or amount_off")
+	s.EqualError((&Coupon{Code: "HUGE", PercentOff: &tooMuch}).Validate(), "percent_off must be between 0 and 100")
+	s.EqualError((&Coupon{Code: "LONG", PercentOff: &percent, Periods: -1}).Validate(), "periods cannot be negative")
+}
+
+func (s *BillingWorkflowTestSuite) Test_Coupon_ValidateRedemption() {
+	amount, _ := money.NewFromString("10.00", money.USD)
+	now := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	expiresAt := now.Add(24 * time.Hour)
+
+	coupon := &Coupon{Code: "TENOFF", AmountOff: &amount, ExpiresAt: &expiresAt, CustomerID: 456}
+
+	s.NoError(coupon.validateRedemption(Redemption{CustomerID: 456}, money.USD, now))
+	s.EqualError(coupon.validateRedemption(Redemption{CustomerID: 456}, money.USD, expiresAt), "coupon TENOFF has expired")
+	s.EqualError(coupon.validateRedemption(Redemption{CustomerID: 789}, money.USD, now), "coupon TENOFF is not valid for this customer")
+	s.EqualError(coupon.validateRedemption(Redemption{CustomerID: 456}, money.GEL, now), "coupon TENOFF is for USD, not GEL")
+
+	coupon.Periods = 1
+	coupon.redeem(Redemption{CustomerID: 456, BillID: "bill-123"})
+	s.EqualError(coupon.validateRedemption(Redemption{CustomerID: 456, BillID: "bill-456"}, money.USD, now), "coupon TENOFF was already used for 1 bills")
+	s.NoError(coupon.validateRedemption(Redemption{CustomerID: 456, SubscriptionID: "sub-123"}, money.USD, now))
+
+	s.NoError(coupon.release(Redemption{CustomerID: 456, BillID: "bill-123"}))
+	s.NoError(coupon.validateRedemption(Redemption{CustomerID: 456, BillID: "bill-456"}, money.USD, now))
+}
+
+func (s *BillingWorkflowTestSuite) Test_Coupon_KeepsLatestRedemptions() {
+	defer func(kept int) { maxCouponRedemptions = kept }(maxCouponRedemptions)
+	maxCouponRedemptions = 2
+
+	percent := decimal.NewFromInt(20)
+	coupon := &Coupon{Code: "SAVE20", PercentOff: &percent}
+
+	coupon.redeem(Redemption{CustomerID: 456, BillID: "bill-1"})
+	coupon.redeem(Redemption{CustomerID: 456, SubscriptionID: "sub-1"})
+	coupon.redeem(Redemption{CustomerID: 789, BillID: "bill-2"})
+
+	s.Equal(3, coupon.Redeemed)
+	s.Equal(2, coupon.BillsRedeemed)
+	s.Require().Len(coupon.Redemptions, 2)
+	s.Equal("sub-1", coupon.Redemptions[0].SubscriptionID)
+	s.Equal("bill-2", coupon.Redemptions[1].BillID)
+}
+
+func (s *BillingWorkflowTestSuite) Test_CouponWorkflow_RedeemsUntilExpired() {
+	start := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	expiresAt := start.Add(48 * time.Hour)
+	percent := decimal.NewFromInt(20)
+
+	s.env.SetStartTime(start)
+
+	var discount *Discount
+	var rejection error
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(UpdateRedeemCoupon, "redeem", &testsuite.TestUpdateCallback{
+			OnAccept: func() {},
+			OnReject: func(err error) { s.Fail("update should not be rejected", err) },
+			OnComplete: func(result interface{}, err error) {
+				s.NoError(err)
+				redeemed := *result.(*Discount)
+				discount = &redeemed
+			},
+		}, RedeemCouponRequest{Redemption: Redemption{CustomerID: 456, BillID: "bill-123"}, Currency: money.USD})
+	}, time.Hour)
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(UpdateRedeemCoupon, "other", &testsuite.TestUpdateCallback{
+			OnAccept:   func() {},
+			OnReject:   func(err error) { rejection = err },
+			OnComplete: func(interface{}, error) {},
+		}, RedeemCouponRequest{Redemption: Redemption{CustomerID: 789, BillID: "bill-456"}, Currency: money.USD})
+	}, time.Hour*2)
+
+	s.env.SetStartWorkflowOptions(client.StartWorkflowOptions{ID: "coupon-SAVE20"})
+	s.env.ExecuteWorkflow(CouponWorkflow, Coupon{
+		Code:       "SAVE20",
+		PercentOff: &percent,
+		Periods:    3,
+		ExpiresAt:  &expiresAt,
+		CustomerID: 456,
+		CreatedAt:  start,
+	})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.EqualError(rejection, "coupon SAVE20 is not valid for this customer")
+
+	s.Require().NotNil(discount)
+	s.Equal("SAVE20", discount.CouponCode)
+	s.Equal("20", discount.PercentOff.String())
+	s.Equal(3, discount.Periods)
+	s.Equal(start.Add(time.Hour), discount.AppliedAt)
+
+	var coupon *Coupon
+	result, err := s.env.QueryWorkflow(QueryGetCoupon)
+	s.NoError(err)
+	s.NoError(result.Get(&coupon))
+	s.Require().Len(coupon.Redemptions, 1)
+	s.Equal("bill-123", coupon.Redemptions[0].BillID)
+	s.Equal(1, coupon.BillsRedeemed)
+}
+
+func (s *BillingWorkflowTestSuite) Test_Subscription_RejectsSecondCoupon() {
+	percent := decimal.NewFromInt(20)
+	subscription := s.subscription()
+	subscription.Discount = &Discount{CouponCode: "SAVE20", PercentOff: &percent, Periods: 2}
+
+	s.EqualError(subscription.validateDiscount(Discount{CouponCode: "SAVE10", PercentOff: &percent}), "subscription already has coupon SAVE20")
+
+	subscription.Discount = nil
+	s.NoError(subscription.validateDiscount(Discount{CouponCode: "SAVE10", PercentOff: &percent}))
+}
+
+func (s *BillingWorkflowTestSuite) Test_SubscriptionWorkflow_AppliesDiscountForLimitedPeriods() {
+	s.env.SetStartTime(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC))
+	s.env.RegisterWorkflow(BillingPeriodWorkflow)
+
+	percent := decimal.NewFromInt(20)
+	subscription := s.subscription()
+	subscription.Discount = &Discount{CouponCode: "SAVE20", PercentOff: &percent, Periods: 2}
+	subscription.DiscountPeriods = 1
+
+	var bills []*Bill
+	s.env.OnActivity(SendBillClosedEmail, mock.Anything, mock.Anything).Return(func(ctx context.Context, details EmailDetails) error {
+		bills = append(bills, details.Bill)
+		return nil
+	})
+
+	s.env.SetStartWorkflowOptions(client.StartWorkflowOptions{ID: "sub-123"})
+	s.env.ExecuteWorkflow(SubscriptionWorkflow, subscription)
+
+	s.True(s.env.IsWorkflowCompleted())
+
+	s.Require().Len(bills, 1)
+	s.Require().Len(bills[0].Discounts, 1)
+	s.Equal("SAVE20", bills[0].Discounts[0].CouponCode)
+	s.Equal("$39.20", bills[0].Total.String())
+
+	var sub *Subscription
+	result, err := s.env.QueryWorkflow(QueryGetSubscription)
+	s.NoError(err)
+	s.NoError(result.Get(&sub))
+	s.Nil(sub.Discount)
+	s.Zero(sub.DiscountPeriods)
+}
+
+func (s *BillingWorkflowTestSuite) Test_CouponWorkflow_ReleasesRedemption() {
+	start := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	expiresAt := start.Add(48 * time.Hour)
+	percent := decimal.NewFromInt(20)
+	redemption := Redemption{CustomerID: 456, BillID: "bill-123"}
+
+	s.env.SetStartTime(start)
+
+	var released *Coupon
+	var rejection error
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(UpdateRedeemCoupon, "redeem", &testsuite.TestUpdateCallback{
+			OnAccept:   func() {},
+			OnReject:   func(err error) { s.Fail("update should not be rejected", err) },
+			OnComplete: func(result interface{}, err error) { s.NoError(err) },
+		}, RedeemCouponRequest{Redemption: redemption, Currency: money.USD})
+	}, time.Hour)
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(UpdateReleaseCoupon, "release", &testsuite.TestUpdateCallback{
+			OnAccept: func() {},
+			OnReject: func(err error) { s.Fail("update should not be rejected", err) },
+			OnComplete: func(result interface{}, err error) {
+				s.NoError(err)
+				coupon := *result.(*Coupon)
+				released = &coupon
+			},
+		}, redemption)
+	}, time.Hour*2)
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(UpdateReleaseCoupon, "release-again", &testsuite.TestUpdateCallback{
+			OnAccept:   func() {},
+			OnReject:   func(err error) { rejection = err },
+			OnComplete: func(interface{}, error) {},
+		}, redemption)
+	}, time.Hour*3)
+
+	s.env.ExecuteWorkflow(CouponWorkflow, Coupon{
+		Code:       "SAVE20",
+		PercentOff: &percent,
+		ExpiresAt:  &expiresAt,
+		CreatedAt:  start,
+	})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.EqualError(rejection, "coupon SAVE20 was not redeemed for this bill or subscription")
+
+	s.Require().NotNil(released)
+	s.Empty(released.Redemptions)
+	s.Zero(released.Redeemed)
+	s.Zero(released.BillsRedeemed)
+}
+
+func (s *BillingWorkflowTestSuite) Test_CouponWorkflow_ContinuesAsNewWithoutExpiry() {
+	defer func(updates int) { couponUpdatesPerRun = updates }(couponUpdatesPerRun)
+	couponUpdatesPerRun = 2
+
+	start := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	percent := decimal.NewFromInt(20)
+
+	s.env.SetStartTime(start)
+
+	for i, billID := range []string{"bill-123", "bill-456"} {
+		request := RedeemCouponRequest{Redemption: Redemption{CustomerID: 456, BillID: billID}, Currency: money.USD}
+		s.env.RegisterDelayedCallback(func() {
+			s.env.UpdateWorkflow(UpdateRedeemCoupon, request.Redemption.BillID, &testsuite.TestUpdateCallback{
+				OnAccept:   func() {},
+				OnReject:   func(err error) { s.Fail("update should not be rejected", err) },
+				OnComplete: func(result interface{}, err error) { s.NoError(err) },
+			}, request)
+		}, time.Duration(i+1)*time.Hour)
+	}
+
+	s.env.ExecuteWorkflow(CouponWorkflow, Coupon{
+		Code:       "SAVE20",
+		PercentOff: &percent,
+		CreatedAt:  start,
+	})
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.True(workflow.IsContinueAsNewError(s.env.GetWorkflowError()))
+
+	var coupon *Coupon
+	result, err := s.env.QueryWorkflow(QueryGetCoupon)
+	s.NoError(err)
+	s.NoError(result.Get(&coupon))
+	s.Len(coupon.Redemptions, 2)
+	s.Equal(2, coupon.Redeemed)
+}
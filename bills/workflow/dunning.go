@@ -0,0 +1,97 @@
+package workflow
+
+import (
+	"time"
+
+	"github.com/sunneydev/pave-billing-api/bills/config"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// runDunning reminds the customer of an unpaid bill on the customer's dunning
+// schedule, counted from when the bill was finalized. Bills that are never
+// finalized were not issued, so they are not reminded. Any payment, even a
+// partial one, cancels the remaining reminders, as does the bill being settled.
+func runDunning(ctx workflow.Context, bill *Bill) {
+	logger := workflow.GetLogger(ctx)
+
+	// bills closed by workflows started before dunning are not reminded
+	if workflow.GetVersion(ctx, ChangeDunning, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		return
+	}
+
+	stopped := func() bool {
+		return bill.Status.IsFinal() || len(bill.Payments) > 0 || !bill.BalanceDue.IsPositive()
+	}
+
+	if err := workflow.Await(ctx, func() bool { return bill.FinalizedAt != nil || stopped() }); err != nil {
+		return
+	}
+
+	if stopped() {
+		logger.Info("bill not dunned", "bill_id", bill.ID, "status", bill.Status)
+		return
+	}
+
+	// recorded in the history so replays use the schedule the bill was issued with
+	var schedule config.DunningSchedule
+	err := workflow.SideEffect(ctx, func(ctx workflow.Context) interface{} {
+		return config.CustomerDunningSchedule(bill.CustomerID)
+	}).Get(&schedule)
+
+	if err != nil {
+		logger.Error("failed to load dunning schedule", "bill_id", bill.ID, "error", err)
+		return
+	}
+
+	issuedAt := *bill.FinalizedAt
+
+	notices := make([]DunningNotice, 0, len(schedule.Reminders)+1)
+	for i, after := range schedule.Reminders {
+		notices = append(notices, DunningNotice{Step: i + 1, DueAt: issuedAt.Add(after)})
+	}
+
+	if schedule.FinalNotice > 0 {
+		notices = append(notices, DunningNotice{Step: len(notices) + 1, Final: true, DueAt: issuedAt.Add(schedule.FinalNotice)})
+	}
+
+	for _, notice := range notices {
+		if wait := notice.DueAt.Sub(workflow.Now(ctx)); wait > 0 {
+			if _, err := workflow.AwaitWithTimeout(ctx, wait, stopped); err != nil {
+				return
+			}
+		}
+
+		if stopped() {
+			logger.Info("dunning stopped", "bill_id", bill.ID, "status", bill.Status)
+			return
+		}
+
+		notice.SentAt = workflow.Now(ctx).UTC()
+		bill.DunningNotices = append(bill.DunningNotices, notice)
+
+		sendDunningNotice(ctx, bill, notice)
+	}
+}
+
+func sendDunningNotice(ctx workflow.Context, bill *Bill, notice DunningNotice) {
+	activityCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute * 5,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute * 5,
+			MaximumAttempts:    5,
+		},
+	})
+
+	err := workflow.ExecuteActivity(activityCtx, SendDunningNotice, DunningDetails{Bill: bill, Notice: notice}).Get(activityCtx, nil)
+	if err != nil {
+		workflow.GetLogger(ctx).Error("Failed to send dunning notice",
+			"error_type", "EMAIL_SERVICE_ERROR",
+			"bill_id", bill.ID,
+			"customer_id", bill.CustomerID,
+			"step", notice.Step,
+			"error", err)
+	}
+}
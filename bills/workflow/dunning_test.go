@@ -0,0 +1,134 @@
+package workflow
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/sunneydev/pave-billing-api/bills/config"
+	"github.com/sunneydev/pave-billing-api/bills/money"
+)
+
+func (s *BillingWorkflowTestSuite) captureDunningNotices() *[]DunningNotice {
+	notices := &[]DunningNotice{}
+
+	s.env.OnActivity(SendDunningNotice, mock.Anything, mock.Anything).Return(func(ctx context.Context, details DunningDetails) error {
+		*notices = append(*notices, details.Notice)
+		return nil
+	})
+
+	return notices
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_SendsDunningNoticesForUnpaidBill() {
+	notices := s.captureDunningNotices()
+
+	s.addLineItem("100.00", time.Second)
+	s.update(UpdateTransitionBill, "close", time.Second*2, transitionTo(BillStatusClosed), nil, nil)
+	s.update(UpdateTransitionBill, "finalize", time.Second*3, transitionTo(BillStatusFinalized), nil, nil)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	s.Require().Len(*notices, 4)
+	for i, notice := range *notices {
+		s.Equal(i+1, notice.Step)
+		s.Equal(i == 3, notice.Final)
+	}
+
+	var bill *Bill
+	result, err := s.env.QueryWorkflow(QueryGetBill)
+	s.NoError(err)
+	s.NoError(result.Get(&bill))
+
+	s.Require().Len(bill.DunningNotices, 4)
+	s.Equal(bill.FinalizedAt.Add(3*24*time.Hour), bill.DunningNotices[0].DueAt)
+	s.Equal(bill.FinalizedAt.Add(21*24*time.Hour), bill.DunningNotices[3].DueAt)
+	s.False(bill.DunningNotices[3].SentAt.Before(bill.DunningNotices[3].DueAt))
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_PartialPaymentCancelsDunning() {
+	notices := s.captureDunningNotices()
+	partial, _ := money.NewFromString("40.00", money.USD)
+
+	var rejection error
+
+	s.addLineItem("100.00", time.Second)
+	s.update(UpdateTransitionBill, "close", time.Second*2, transitionTo(BillStatusClosed), nil, nil)
+	s.update(UpdateTransitionBill, "finalize", time.Second*3, transitionTo(BillStatusFinalized), nil, nil)
+	s.update(UpdateApplyPayment, "p1", 5*24*time.Hour, constant(Payment{ID: "p1", Amount: partial, Method: PaymentMethodCard}), nil, &rejection)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.NoError(rejection)
+
+	s.Require().Len(*notices, 1)
+	s.Equal(1, (*notices)[0].Step)
+
+	var bill *Bill
+	result, err := s.env.QueryWorkflow(QueryGetBill)
+	s.NoError(err)
+	s.NoError(result.Get(&bill))
+	s.Equal(BillStatusUncollectible, bill.Status)
+	s.Equal("$60.00", bill.BalanceDue.String())
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_UsesCustomerDunningSchedule() {
+	config.CustomerDunningSchedules[789] = config.DunningSchedule{
+		Reminders:   []time.Duration{24 * time.Hour},
+		FinalNotice: 48 * time.Hour,
+	}
+	config.CustomerDunningSchedules[790] = config.DunningSchedule{}
+
+	defer func() {
+		delete(config.CustomerDunningSchedules, 789)
+		delete(config.CustomerDunningSchedules, 790)
+	}()
+
+	notices := s.captureDunningNotices()
+
+	s.addLineItem("10.00", time.Second)
+	s.update(UpdateTransitionBill, "close", time.Second*2, transitionTo(BillStatusClosed), nil, nil)
+	s.update(UpdateTransitionBill, "finalize", time.Second*3, transitionTo(BillStatusFinalized), nil, nil)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 789, money.USD, BillingPeriod{}, false)
+
+	s.NoError(s.env.GetWorkflowError())
+	s.Require().Len(*notices, 2)
+	s.True((*notices)[1].Final)
+
+	s.SetupTest()
+
+	s.addLineItem("10.00", time.Second)
+	s.update(UpdateTransitionBill, "close", time.Second*2, transitionTo(BillStatusClosed), nil, nil)
+	s.update(UpdateTransitionBill, "finalize", time.Second*3, transitionTo(BillStatusFinalized), nil, nil)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-456", 790, money.USD, BillingPeriod{}, false)
+
+	s.NoError(s.env.GetWorkflowError())
+
+	var bill *Bill
+	result, err := s.env.QueryWorkflow(QueryGetBill)
+	s.NoError(err)
+	s.NoError(result.Get(&bill))
+	s.Empty(bill.DunningNotices)
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_DoesNotDunUnfinalizedBill() {
+	s.addLineItem("100.00", time.Second)
+	s.update(UpdateTransitionBill, "close", time.Second*2, transitionTo(BillStatusClosed), nil, nil)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	var bill *Bill
+	result, err := s.env.QueryWorkflow(QueryGetBill)
+	s.NoError(err)
+	s.NoError(result.Get(&bill))
+	s.Equal(BillStatusUncollectible, bill.Status)
+	s.Empty(bill.DunningNotices)
+}
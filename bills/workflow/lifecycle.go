@@ -0,0 +1,117 @@
+package workflow
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultPaymentTerms is how long after finalization a bill is due.
+const DefaultPaymentTerms = 30 * 24 * time.Hour
+
+// billTransitions lists the statuses each status can move to.
+// PAID, VOID and UNCOLLECTIBLE are final.
+var billTransitions = map[BillStatus][]BillStatus{
+	BillStatusDraft:         {BillStatusOpen},
+	BillStatusOpen:          {BillStatusClosed},
+	BillStatusClosed:        {BillStatusFinalized, BillStatusUncollectible},
+	BillStatusFinalized:     {BillStatusPaid, BillStatusPartiallyPaid, BillStatusOverdue, BillStatusVoid, BillStatusUncollectible},
+	BillStatusPartiallyPaid: {BillStatusPaid, BillStatusOverdue, BillStatusUncollectible},
+	BillStatusOverdue:       {BillStatusPaid, BillStatusVoid, BillStatusUncollectible},
+}
+
+func (s BillStatus) IsValid() bool {
+	switch s {
+	case BillStatusDraft, BillStatusOpen, BillStatusClosed, BillStatusFinalized, BillStatusPaid,
+		BillStatusPartiallyPaid, BillStatusOverdue, BillStatusVoid, BillStatusUncollectible:
+		return true
+	}
+
+	return false
+}
+
+// IsFinal reports whether the bill can no longer change status.
+func (s BillStatus) IsFinal() bool {
+	_, ok := billTransitions[s]
+	return !ok
+}
+
+// IsEditable reports whether line items can still be added or voided.
+func (s BillStatus) IsEditable() bool {
+	return s == BillStatusDraft || s == BillStatusOpen
+}
+
+// IsOwed reports whether the bill has been closed and is not yet settled.
+func (s BillStatus) IsOwed() bool {
+	return s == BillStatusClosed || s == BillStatusFinalized || s == BillStatusPartiallyPaid || s == BillStatusOverdue
+}
+
+// CanTransitionTo reports whether a bill in status s may move to status to.
+func (s BillStatus) CanTransitionTo(to BillStatus) bool {
+	for _, next := range billTransitions[s] {
+		if next == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (b *Bill) validateTransition(request TransitionBillRequest) error {
+	if !request.Status.IsValid() {
+		return fmt.Errorf("invalid bill status: %s", request.Status)
+	}
+
+	if request.Status == BillStatusPaid || request.Status == BillStatusPartiallyPaid {
+		return fmt.Errorf("bills are marked %s by applying payments", request.Status)
+	}
+
+	if !b.Status.CanTransitionTo(request.Status) {
+		return fmt.Errorf("bill cannot move from %s to %s", b.Status, request.Status)
+	}
+
+	if request.Status == BillStatusFinalized && request.DueAt != nil && request.DueAt.Before(request.ChangedAt) {
+		return fmt.Errorf("due date is before the finalization date")
+	}
+
+	return nil
+}
+
+func (b *Bill) transition(request TransitionBillRequest) error {
+	if err := b.validateTransition(request); err != nil {
+		return err
+	}
+
+	switch request.Status {
+	case BillStatusClosed:
+		// closing also computes taxes, which needs the workflow, see closeBill
+		return fmt.Errorf("bills are closed with closeBill")
+	case BillStatusFinalized:
+		dueAt := request.ChangedAt.Add(DefaultPaymentTerms)
+		if request.DueAt != nil {
+			dueAt = *request.DueAt
+		}
+
+		b.FinalizedAt = &request.ChangedAt
+		b.DueAt = &dueAt
+	}
+
+	b.setStatus(request.Status, request.ChangedAt, request.Reason)
+
+	// a bill with nothing left to pay is settled as soon as it is issued
+	if request.Status == BillStatusFinalized {
+		b.settle(request.ChangedAt)
+	}
+
+	return nil
+}
+
+func (b *Bill) setStatus(status BillStatus, changedAt time.Time, reason string) {
+	b.StatusHistory = append(b.StatusHistory, StatusChange{
+		From:      b.Status,
+		To:        status,
+		ChangedAt: changedAt,
+		Reason:    reason,
+	})
+
+	b.Status = status
+}
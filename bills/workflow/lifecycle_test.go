@@ -0,0 +1,224 @@
+package workflow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sunneydev/pave-billing-api/bills/money"
+	"go.temporal.io/sdk/testsuite"
+)
+
+func Test_BillStatus_CanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to BillStatus
+		allowed  bool
+	}{
+		{BillStatusDraft, BillStatusOpen, true},
+		{BillStatusDraft, BillStatusClosed, false},
+		{BillStatusOpen, BillStatusClosed, true},
+		{BillStatusOpen, BillStatusFinalized, false},
+		{BillStatusClosed, BillStatusFinalized, true},
+		{BillStatusClosed, BillStatusPaid, false},
+		{BillStatusClosed, BillStatusUncollectible, true},
+		{BillStatusFinalized, BillStatusPaid, true},
+		{BillStatusFinalized, BillStatusVoid, true},
+		{BillStatusPartiallyPaid, BillStatusPaid, true},
+		{BillStatusPartiallyPaid, BillStatusVoid, false},
+		{BillStatusOverdue, BillStatusUncollectible, true},
+		{BillStatusPaid, BillStatusVoid, false},
+		{BillStatusVoid, BillStatusOpen, false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.allowed, tt.from.CanTransitionTo(tt.to), "%s -> %s", tt.from, tt.to)
+	}
+
+	assert.True(t, BillStatusPaid.IsFinal())
+	assert.True(t, BillStatusUncollectible.IsFinal())
+	assert.False(t, BillStatusOverdue.IsFinal())
+	assert.False(t, BillStatus("SETTLED").IsValid())
+}
+
+// transitionTo builds a request to move the bill to status when it is sent.
+func transitionTo(status BillStatus) func(time.Time) interface{} {
+	return func(now time.Time) interface{} {
+		return TransitionBillRequest{Status: status, ChangedAt: now}
+	}
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_FollowsLifecycleUntilVoided() {
+	s.env.SetStartTime(time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC))
+
+	var rejection error
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(UpdateCloseBill, "close-draft", &testsuite.TestUpdateCallback{
+			OnAccept:   func() { s.Fail("closing a draft should be rejected") },
+			OnReject:   func(err error) { rejection = err },
+			OnComplete: func(interface{}, error) {},
+		}, CloseBillSignal{ClosedAt: s.env.Now().UTC()})
+	}, time.Second)
+
+	s.update(UpdateTransitionBill, "open", time.Second*2, transitionTo(BillStatusOpen), nil, nil)
+	s.addLineItem("100.00", time.Second*2+time.Millisecond*500)
+	s.update(UpdateTransitionBill, "close", time.Second*3, transitionTo(BillStatusClosed), nil, nil)
+	s.update(UpdateTransitionBill, "finalize", time.Second*4, transitionTo(BillStatusFinalized), nil, nil)
+	s.update(UpdateTransitionBill, "void", DefaultPaymentTerms+time.Hour, func(now time.Time) interface{} {
+		return TransitionBillRequest{Status: BillStatusVoid, Reason: "issued in error", ChangedAt: now}
+	}, nil, nil)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, true)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.ErrorContains(rejection, "bill cannot move from DRAFT to CLOSED")
+
+	var bill *Bill
+	result, err := s.env.QueryWorkflow(QueryGetBill)
+	s.NoError(err)
+	s.NoError(result.Get(&bill))
+
+	s.Equal(BillStatusVoid, bill.Status)
+	s.Require().NotNil(bill.DueAt)
+	s.Equal(bill.FinalizedAt.Add(DefaultPaymentTerms), *bill.DueAt)
+
+	statuses := make([]BillStatus, 0, len(bill.StatusHistory))
+	for _, change := range bill.StatusHistory {
+		statuses = append(statuses, change.To)
+	}
+
+	s.Equal([]BillStatus{BillStatusOpen, BillStatusClosed, BillStatusFinalized, BillStatusOverdue, BillStatusVoid}, statuses)
+	s.Equal("issued in error", bill.StatusHistory[4].Reason)
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_ClosesDraftOnlyOnceOpened() {
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	opened := start.Add(30 * 24 * time.Hour)
+	s.env.SetStartTime(start)
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(SignalCloseBill, CloseBillSignal{ClosedAt: s.env.Now().UTC()})
+	}, time.Second)
+
+	var atPeriodEnd *Bill
+
+	s.env.RegisterDelayedCallback(func() {
+		result, err := s.env.QueryWorkflow(QueryGetBill)
+		s.NoError(err)
+		s.NoError(result.Get(&atPeriodEnd))
+
+		s.env.UpdateWorkflow(UpdateTransitionBill, "open", &testsuite.TestUpdateCallback{
+			OnAccept:   func() {},
+			OnReject:   func(err error) { s.Fail("update should not be rejected", err) },
+			OnComplete: func(interface{}, error) {},
+		}, TransitionBillRequest{Status: BillStatusOpen, ChangedAt: s.env.Now().UTC()})
+	}, opened.Sub(start))
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, true)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	s.Require().NotNil(atPeriodEnd)
+	s.Equal(BillStatusDraft, atPeriodEnd.Status)
+	s.Nil(atPeriodEnd.ClosedAt)
+
+	var bill *Bill
+	result, err := s.env.QueryWorkflow(QueryGetBill)
+	s.NoError(err)
+	s.NoError(result.Get(&bill))
+
+	s.Require().NotNil(bill.ClosedAt)
+	s.Equal(opened, *bill.ClosedAt)
+	s.Require().Len(bill.StatusHistory, 2)
+	s.Equal(StatusChange{From: BillStatusDraft, To: BillStatusOpen, ChangedAt: opened}, bill.StatusHistory[0])
+	s.Equal(StatusChange{From: BillStatusOpen, To: BillStatusClosed, ChangedAt: opened}, bill.StatusHistory[1])
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_RejectsInvalidTransition() {
+	var rejection error
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(UpdateTransitionBill, "finalize-open-bill", &testsuite.TestUpdateCallback{
+			OnAccept:   func() { s.Fail("finalizing an open bill should be rejected") },
+			OnReject:   func(err error) { rejection = err },
+			OnComplete: func(interface{}, error) {},
+		}, TransitionBillRequest{Status: BillStatusFinalized, ChangedAt: s.env.Now().UTC()})
+	}, time.Second)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.ErrorContains(rejection, "bill cannot move from OPEN to FINALIZED")
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_WritesOffUnpaidBillAfterRetention() {
+	s.addLineItem("100.00", time.Millisecond*500)
+	s.update(UpdateTransitionBill, "close", time.Second, transitionTo(BillStatusClosed), nil, nil)
+	s.update(UpdateTransitionBill, "finalize", time.Second*2, transitionTo(BillStatusFinalized), nil, nil)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	var bill *Bill
+	result, err := s.env.QueryWorkflow(QueryGetBill)
+	s.NoError(err)
+	s.NoError(result.Get(&bill))
+
+	s.Equal(BillStatusUncollectible, bill.Status)
+	s.Equal(BillStatusOverdue, bill.StatusHistory[len(bill.StatusHistory)-1].From)
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_FinalizingEmptyBillSettlesIt() {
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	s.env.SetStartTime(start)
+
+	s.update(UpdateTransitionBill, "close", time.Second, transitionTo(BillStatusClosed), nil, nil)
+	s.update(UpdateTransitionBill, "finalize", time.Second*2, transitionTo(BillStatusFinalized), nil, nil)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	var bill *Bill
+	result, err := s.env.QueryWorkflow(QueryGetBill)
+	s.NoError(err)
+	s.NoError(result.Get(&bill))
+
+	s.Equal(BillStatusPaid, bill.Status)
+	s.True(bill.BalanceDue.IsZero())
+
+	statuses := make([]BillStatus, 0, len(bill.StatusHistory))
+	for _, change := range bill.StatusHistory {
+		statuses = append(statuses, change.To)
+	}
+
+	s.Equal([]BillStatus{BillStatusClosed, BillStatusFinalized, BillStatusPaid}, statuses)
+
+	// settled at once, so the workflow neither waits for the due date nor writes the bill off
+	s.True(s.env.Now().Before(start.Add(DefaultPaymentTerms)))
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_WritesOffUnfinalizedBillAfterRetention() {
+	s.addLineItem("100.00", time.Millisecond*500)
+	s.update(UpdateTransitionBill, "close", time.Second, transitionTo(BillStatusClosed), nil, nil)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	var bill *Bill
+	result, err := s.env.QueryWorkflow(QueryGetBill)
+	s.NoError(err)
+	s.NoError(result.Get(&bill))
+
+	s.Equal(BillStatusUncollectible, bill.Status)
+	s.Equal(BillStatusClosed, bill.StatusHistory[len(bill.StatusHistory)-1].From)
+	s.Equal("$100.00", bill.BalanceDue.String())
+}
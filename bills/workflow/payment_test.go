@@ -0,0 +1,89 @@
+package workflow
+
+import (
+	"time"
+
+	"github.com/sunneydev/pave-billing-api/bills/money"
+)
+
+func (s *BillingWorkflowTestSuite) addLineItem(amount string, delay time.Duration) {
+	charge, _ := money.NewFromString(amount, money.USD)
+
+	s.update(UpdateAddLineItems, "add-"+amount, delay, func(now time.Time) interface{} {
+		return AddLineItemsRequest{LineItems: []LineItem{{ID: "charge-" + amount, Amount: charge, CreatedAt: now}}}
+	}, nil, nil)
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_PartialThenFullPayment() {
+	partial, _ := money.NewFromString("40.00", money.USD)
+	rest, _ := money.NewFromString("60.00", money.USD)
+
+	var afterPartial, afterFull *Bill
+	var rejection error
+
+	s.addLineItem("100.00", time.Second)
+	s.update(UpdateTransitionBill, "close", time.Second*2, transitionTo(BillStatusClosed), nil, nil)
+	s.update(UpdateTransitionBill, "finalize", time.Second*3, transitionTo(BillStatusFinalized), nil, nil)
+	s.update(UpdateApplyPayment, "p1", time.Second*4, constant(Payment{ID: "p1", Amount: partial, Method: PaymentMethodCard, Reference: "ch_1"}), &afterPartial, &rejection)
+	s.update(UpdateApplyPayment, "p2", time.Second*5, constant(Payment{ID: "p2", Amount: rest, Method: PaymentMethodBankTransfer, Reference: "tr_1"}), &afterFull, &rejection)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.NoError(rejection)
+
+	s.Require().NotNil(afterPartial)
+	s.Equal(BillStatusPartiallyPaid, afterPartial.Status)
+	s.Equal("$40.00", afterPartial.AmountPaid.String())
+	s.Equal("$60.00", afterPartial.BalanceDue.String())
+
+	s.Require().NotNil(afterFull)
+	s.Equal(BillStatusPaid, afterFull.Status)
+	s.Equal("$100.00", afterFull.AmountPaid.String())
+	s.True(afterFull.BalanceDue.IsZero())
+	s.Len(afterFull.Payments, 2)
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_PaymentFinalizesClosedBill() {
+	full, _ := money.NewFromString("25.00", money.USD)
+
+	var paid *Bill
+	var rejection error
+
+	s.addLineItem("25.00", time.Second)
+	s.update(UpdateTransitionBill, "close", time.Second*2, transitionTo(BillStatusClosed), nil, nil)
+	s.update(UpdateApplyPayment, "p1", time.Second*3, constant(Payment{ID: "p1", Amount: full, Method: PaymentMethodCash}), &paid, &rejection)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.NoError(s.env.GetWorkflowError())
+	s.NoError(rejection)
+	s.Require().NotNil(paid)
+	s.Equal(BillStatusPaid, paid.Status)
+	s.NotNil(paid.FinalizedAt)
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_RejectsInvalidPayments() {
+	ten, _ := money.NewFromString("10.00", money.USD)
+	tooMuch, _ := money.NewFromString("10.01", money.USD)
+	gel, _ := money.NewFromString("5.00", money.GEL)
+
+	var openRejection, overpaid, wrongCurrency, duplicate error
+
+	s.addLineItem("20.00", time.Second)
+	s.update(UpdateApplyPayment, "open", time.Second*2, constant(Payment{ID: "open", Amount: ten, Method: PaymentMethodCard}), nil, &openRejection)
+	s.update(UpdateTransitionBill, "close", time.Second*3, transitionTo(BillStatusClosed), nil, nil)
+	s.update(UpdateApplyPayment, "first", time.Second*4, constant(Payment{ID: "first", Amount: ten, Method: PaymentMethodCard, Reference: "ch_1"}), nil, nil)
+	s.update(UpdateApplyPayment, "overpaid", time.Second*5, constant(Payment{ID: "overpaid", Amount: tooMuch, Method: PaymentMethodCard}), nil, &overpaid)
+	s.update(UpdateApplyPayment, "gel", time.Second*6, constant(Payment{ID: "gel", Amount: gel, Method: PaymentMethodCard}), nil, &wrongCurrency)
+	s.update(UpdateApplyPayment, "duplicate", time.Second*7, constant(Payment{ID: "duplicate", Amount: ten, Method: PaymentMethodCard, Reference: "ch_1"}), nil, &duplicate)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.NoError(s.env.GetWorkflowError())
+	s.ErrorContains(openRejection, "payments cannot be applied to a OPEN bill")
+	s.ErrorContains(overpaid, "exceeds the balance due")
+	s.ErrorContains(wrongCurrency, "does not match bill currency")
+	s.ErrorContains(duplicate, `reference "ch_1" was already applied`)
+}
@@ -0,0 +1,226 @@
+package workflow
+
+import (
+	"fmt"
+
+	enumspb "go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/workflow"
+)
+
+// SubscriptionWorkflow runs one BillingPeriodWorkflow per billing period as a child,
+// adding the recurring charge to each bill, and continues as new after every
+// period so its history stays small. Bills are abandoned rather than cancelled
+// with the subscription, so the current period is still billed.
+func SubscriptionWorkflow(ctx workflow.Context, subscription Subscription) error {
+	logger := workflow.GetLogger(ctx)
+	sub := &subscription
+
+	err := workflow.SetQueryHandler(ctx, QueryGetSubscription, func() (*Subscription, error) {
+		return sub, nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to register query handler: %v", err)
+	}
+
+	transitions := []struct {
+		name string
+		from []SubscriptionStatus
+		to   SubscriptionStatus
+	}{
+		{UpdatePauseSubscription, []SubscriptionStatus{SubscriptionStatusActive}, SubscriptionStatusPaused},
+		{UpdateResumeSubscription, []SubscriptionStatus{SubscriptionStatusPaused}, SubscriptionStatusActive},
+		{UpdateCancelSubscription, []SubscriptionStatus{SubscriptionStatusActive, SubscriptionStatusPaused}, SubscriptionStatusCancelled},
+	}
+
+	for _, transition := range transitions {
+		transition := transition
+
+		validate := func() error {
+			for _, status := range transition.from {
+				if sub.Status == status {
+					return nil
+				}
+			}
+
+			return fmt.Errorf("subscription is %s", sub.Status)
+		}
+
+		err = workflow.SetUpdateHandlerWithOptions(ctx, transition.name,
+			func(ctx workflow.Context) (*Subscription, error) {
+				if err := validate(); err != nil {
+					return nil, err
+				}
+
+				sub.Status = transition.to
+				if transition.to == SubscriptionStatusCancelled {
+					now := workflow.Now(ctx).UTC()
+					sub.CancelledAt = &now
+				}
+
+				logger.Info("subscription status changed", "subscription_id", sub.ID, "status", sub.Status)
+
+				return sub, nil
+			},
+			workflow.UpdateHandlerOptions{Validator: validate},
+		)
+
+		if err != nil {
+			return fmt.Errorf("failed to register update handler: %v", err)
+		}
+	}
+
+	err = workflow.SetUpdateHandlerWithOptions(ctx, UpdateDiscountSubscription,
+		func(ctx workflow.Context, discount Discount) (*Subscription, error) {
+			if err := sub.validateDiscount(discount); err != nil {
+				return nil, err
+			}
+
+			sub.Discount = &discount
+			sub.DiscountPeriods = discount.Periods
+
+			logger.Info("subscription discounted", "subscription_id", sub.ID, "coupon", discount.CouponCode)
+
+			return sub, nil
+		},
+		workflow.UpdateHandlerOptions{Validator: sub.validateDiscount},
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to register update handler: %v", err)
+	}
+
+	stopped := func() bool { return sub.Status == SubscriptionStatusCancelled }
+
+	if err = workflow.Await(ctx, func() bool { return sub.Status != SubscriptionStatusPaused }); err != nil {
+		return err
+	}
+
+	if !stopped() {
+		if err = runSubscriptionPeriod(ctx, sub); err != nil {
+			return err
+		}
+	}
+
+	if err = workflow.Await(ctx, func() bool { return workflow.AllHandlersFinished(ctx) }); err != nil {
+		return err
+	}
+
+	if stopped() {
+		logger.Info("subscription cancelled", "subscription_id", sub.ID)
+		return nil
+	}
+
+	return workflow.NewContinueAsNewError(ctx, SubscriptionWorkflow, *sub)
+}
+
+// runSubscriptionPeriod bills one period and returns once its bill has closed and
+// the period has ended, even if the bill was closed early, so the next bill covers the next period.
+func runSubscriptionPeriod(ctx workflow.Context, sub *Subscription) error {
+	now := workflow.Now(ctx).UTC()
+
+	// custom periods follow on from the previous one, unless it ended while paused
+	period := sub.Period
+	if period.Interval == BillingIntervalCustom && sub.NextPeriodStart.Add(period.Duration).After(now) {
+		period.Start = sub.NextPeriodStart
+	}
+
+	_, periodEnd, err := period.Bounds(now)
+	if err != nil {
+		return fmt.Errorf("invalid billing period: %v", err)
+	}
+
+	billID := fmt.Sprintf("%s-%d", sub.ID, sub.PeriodsBilled+1)
+
+	childCtx := workflow.WithChildOptions(ctx, workflow.ChildWorkflowOptions{
+		WorkflowID:        billID,
+		ParentClosePolicy: enumspb.PARENT_CLOSE_POLICY_ABANDON,
+		SearchAttributes:  map[string]interface{}{"CustomerID": sub.CustomerID},
+	})
+
+	child := workflow.ExecuteChildWorkflow(childCtx, BillingPeriodWorkflow, billID, sub.CustomerID, sub.Currency, period, false)
+	if err = child.GetChildWorkflowExecution().Get(ctx, nil); err != nil {
+		return fmt.Errorf("failed to start bill: %v", err)
+	}
+
+	sub.CurrentBillID = billID
+
+	if sub.RecurringCharge != nil {
+		lineItem := LineItem{
+			ID:        billID + "-recurring",
+			Type:      LineItemTypeRecurring,
+			Amount:    *sub.RecurringCharge,
+			CreatedAt: now,
+		}
+
+		if err = child.SignalChildWorkflow(ctx, SignalAddLineItem, lineItem).Get(ctx, nil); err != nil {
+			return fmt.Errorf("failed to add recurring charge: %v", err)
+		}
+	}
+
+	if sub.Discount != nil {
+		if err = child.SignalChildWorkflow(ctx, SignalApplyDiscount, *sub.Discount).Get(ctx, nil); err != nil {
+			return fmt.Errorf("failed to apply discount: %v", err)
+		}
+
+		if sub.DiscountPeriods > 0 {
+			sub.DiscountPeriods--
+			if sub.DiscountPeriods == 0 {
+				sub.Discount = nil
+			}
+		}
+	}
+
+	// bills stay open after closing until they are settled,
+	// so the bill signals once it has closed instead of completing
+	closedChan := workflow.GetSignalChannel(ctx, SignalBillClosed)
+	closed := false
+
+	for !closed {
+		selector := workflow.NewSelector(ctx)
+
+		selector.AddReceive(closedChan, func(ch workflow.ReceiveChannel, more bool) {
+			var closedID string
+			ch.Receive(ctx, &closedID)
+			closed = closedID == billID
+		})
+
+		selector.AddFuture(child, func(f workflow.Future) {
+			if err := f.Get(ctx, nil); err != nil {
+				workflow.GetLogger(ctx).Error("bill failed", "subscription_id", sub.ID, "bill_id", billID, "error", err)
+			}
+
+			closed = true
+		})
+
+		selector.Select(ctx)
+	}
+
+	if remaining := periodEnd.Sub(workflow.Now(ctx)); remaining > 0 {
+		if _, err = workflow.AwaitWithTimeout(ctx, remaining, func() bool { return sub.Status == SubscriptionStatusCancelled }); err != nil {
+			return err
+		}
+	}
+
+	sub.CurrentBillID = ""
+	sub.PeriodsBilled++
+	sub.NextPeriodStart = periodEnd
+
+	return nil
+}
+
+func (s *Subscription) validateDiscount(discount Discount) error {
+	if s.Status == SubscriptionStatusCancelled {
+		return fmt.Errorf("subscription is %s", s.Status)
+	}
+
+	if discount.AmountOff != nil && discount.AmountOff.Currency != s.Currency {
+		return fmt.Errorf("discount currency %s does not match subscription currency %s", discount.AmountOff.Currency, s.Currency)
+	}
+
+	if s.Discount != nil {
+		return fmt.Errorf("subscription already has coupon %s", s.Discount.CouponCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package workflow
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/sunneydev/pave-billing-api/bills/money"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+func (s *BillingWorkflowTestSuite) subscription() Subscription {
+	charge, _ := money.NewFromString("49.00", money.USD)
+
+	return Subscription{
+		ID:              "sub-123",
+		CustomerID:      456,
+		Currency:        money.USD,
+		Period:          BillingPeriod{Interval: BillingIntervalWeekly},
+		RecurringCharge: &charge,
+		Status:          SubscriptionStatusActive,
+		CreatedAt:       time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+func (s *BillingWorkflowTestSuite) Test_SubscriptionWorkflow_BillsPeriodAndContinuesAsNew() {
+	s.env.SetStartTime(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC))
+	s.env.RegisterWorkflow(BillingPeriodWorkflow)
+
+	var bills []*Bill
+	s.env.OnActivity(SendBillClosedEmail, mock.Anything, mock.Anything).Return(func(ctx context.Context, details EmailDetails) error {
+		bills = append(bills, details.Bill)
+		return nil
+	})
+
+	s.env.SetStartWorkflowOptions(client.StartWorkflowOptions{ID: "sub-123"})
+	s.env.ExecuteWorkflow(SubscriptionWorkflow, s.subscription())
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.True(workflow.IsContinueAsNewError(s.env.GetWorkflowError()))
+
+	s.Require().Len(bills, 1)
+	s.Equal("sub-123-1", bills[0].ID)
+	s.Equal("sub-123", bills[0].SubscriptionID)
+	s.Equal(BillStatusClosed, bills[0].Status)
+	s.Equal(time.Date(2025, 1, 20, 0, 0, 0, 0, time.UTC), bills[0].PeriodEnd)
+	s.Require().Len(bills[0].LineItems, 1)
+	s.Equal(LineItemTypeRecurring, bills[0].LineItems[0].Type)
+	s.Equal("$49.00", bills[0].Total.String())
+
+	var sub *Subscription
+	result, err := s.env.QueryWorkflow(QueryGetSubscription)
+	s.NoError(err)
+	s.NoError(result.Get(&sub))
+	s.Equal(1, sub.PeriodsBilled)
+	s.Equal(bills[0].PeriodEnd, sub.NextPeriodStart)
+	s.Empty(sub.CurrentBillID)
+}
+
+func (s *BillingWorkflowTestSuite) Test_SubscriptionWorkflow_CancelStopsAfterCurrentBill() {
+	s.env.SetStartTime(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC))
+	s.env.RegisterWorkflow(BillingPeriodWorkflow)
+
+	emails := 0
+	s.env.OnActivity(SendBillClosedEmail, mock.Anything, mock.Anything).Return(func(ctx context.Context, details EmailDetails) error {
+		emails++
+		return nil
+	})
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(UpdateCancelSubscription, "cancel", &testsuite.TestUpdateCallback{
+			OnAccept: func() {},
+			OnReject: func(err error) { s.Fail("update should not be rejected", err) },
+			OnComplete: func(result interface{}, err error) {
+				s.NoError(err)
+				s.Equal(SubscriptionStatusCancelled, result.(*Subscription).Status)
+				s.NotNil(result.(*Subscription).CancelledAt)
+				s.Equal("sub-123-1", result.(*Subscription).CurrentBillID)
+			},
+		})
+	}, time.Hour)
+
+	s.env.ExecuteWorkflow(SubscriptionWorkflow, s.subscription())
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.Equal(1, emails)
+}
+
+func (s *BillingWorkflowTestSuite) Test_SubscriptionWorkflow_RejectsInvalidTransitions() {
+	s.env.RegisterWorkflow(BillingPeriodWorkflow)
+
+	var rejection error
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(UpdateResumeSubscription, "resume", &testsuite.TestUpdateCallback{
+			OnAccept:   func() { s.Fail("resuming an active subscription should be rejected") },
+			OnReject:   func(err error) { rejection = err },
+			OnComplete: func(interface{}, error) {},
+		})
+	}, time.Second)
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(UpdatePauseSubscription, "pause", &testsuite.TestUpdateCallback{
+			OnAccept: func() {},
+			OnReject: func(err error) { s.Fail("update should not be rejected", err) },
+			OnComplete: func(result interface{}, err error) {
+				s.NoError(err)
+				s.Equal(SubscriptionStatusPaused, result.(*Subscription).Status)
+			},
+		})
+	}, time.Second*2)
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(UpdateCancelSubscription, "cancel", &testsuite.TestUpdateCallback{
+			OnAccept:   func() {},
+			OnReject:   func(err error) { s.Fail("update should not be rejected", err) },
+			OnComplete: func(interface{}, error) {},
+		})
+	}, time.Second*3)
+
+	s.env.ExecuteWorkflow(SubscriptionWorkflow, s.subscription())
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.ErrorContains(rejection, "subscription is ACTIVE")
+}
@@ -1,35 +1,369 @@
 package workflow
 
 import (
+	"encoding/json"
 	"time"
 
+	"github.com/shopspring/decimal"
 	"github.com/sunneydev/pave-billing-api/bills/money"
+	"github.com/sunneydev/pave-billing-api/bills/tax"
 )
 
 type BillStatus string
 
+// A bill is DRAFT or OPEN while line items can be added, CLOSED once its total
+// is final and FINALIZED once it has been issued with a due date. Finalized bills
+// end as PAID, VOID or UNCOLLECTIBLE, possibly PARTIALLY_PAID or OVERDUE first.
+// Closed bills that are never finalized are written off as UNCOLLECTIBLE.
 const (
-	BillStatusOpen   BillStatus = "OPEN"
-	BillStatusClosed BillStatus = "CLOSED"
+	BillStatusDraft         BillStatus = "DRAFT"
+	BillStatusOpen          BillStatus = "OPEN"
+	BillStatusClosed        BillStatus = "CLOSED"
+	BillStatusFinalized     BillStatus = "FINALIZED"
+	BillStatusPaid          BillStatus = "PAID"
+	BillStatusPartiallyPaid BillStatus = "PARTIALLY_PAID"
+	BillStatusOverdue       BillStatus = "OVERDUE"
+	BillStatusVoid          BillStatus = "VOID"
+	BillStatusUncollectible BillStatus = "UNCOLLECTIBLE"
 )
 
+type BillingInterval string
+
+const (
+	BillingIntervalDaily     BillingInterval = "DAILY"
+	BillingIntervalWeekly    BillingInterval = "WEEKLY"
+	BillingIntervalMonthly   BillingInterval = "MONTHLY"
+	BillingIntervalQuarterly BillingInterval = "QUARTERLY"
+	BillingIntervalAnnual    BillingInterval = "ANNUAL"
+	BillingIntervalCustom    BillingInterval = "CUSTOM"
+)
+
+// BillingPeriod configures when a bill closes. The zero value is a calendar
+// month in UTC. AnchorDay is the day of the month for monthly, quarterly and
+// annual periods and the weekday (1 = Monday, 7 = Sunday) for weekly ones.
+// Duration and Start are only used by custom periods.
+type BillingPeriod struct {
+	Interval  BillingInterval `json:"interval,omitempty"`
+	AnchorDay int             `json:"anchor_day,omitempty"`
+	Timezone  string          `json:"timezone,omitempty"`
+	Duration  time.Duration   `json:"duration,omitempty"`
+	Start     time.Time       `json:"start,omitempty"`
+}
+
 type Bill struct {
-	ID         string         `json:"id"`
-	CustomerID int            `json:"customer_id"`
-	Status     BillStatus     `json:"status"`
-	Currency   money.Currency `json:"currency"`
-	CreatedAt  time.Time      `json:"created_at"`
-	ClosedAt   *time.Time     `json:"closed_at,omitempty"`
-	LineItems  []LineItem     `json:"line_items"`
-	Total      money.Money    `json:"total"`
+	ID             string         `json:"id"`
+	CustomerID     int            `json:"customer_id"`
+	SubscriptionID string         `json:"subscription_id,omitempty"`
+	Status         BillStatus     `json:"status"`
+	Currency       money.Currency `json:"currency"`
+	CreatedAt      time.Time      `json:"created_at"`
+	ClosedAt       *time.Time     `json:"closed_at,omitempty"`
+	FinalizedAt    *time.Time     `json:"finalized_at,omitempty"`
+	DueAt          *time.Time     `json:"due_at,omitempty"`
+	StatusHistory  []StatusChange `json:"status_history,omitempty"`
+
+	Period      BillingPeriod `json:"period"`
+	PeriodStart time.Time     `json:"period_start"`
+	PeriodEnd   time.Time     `json:"period_end"`
+
+	LineItems []LineItem `json:"line_items"`
+	// Subtotal is the exact sum of the line items. Total is Subtotal rounded to
+	// the currency's minor units, and is final once the bill is closed.
+	// Tax is the tax added to the total when the bill closes. TaxLines also show
+	// taxes already included in the prices, which are not added again.
+	Subtotal money.Precise `json:"subtotal"`
+	Tax      money.Money   `json:"tax"`
+	TaxLines []tax.TaxLine `json:"tax_lines,omitempty"`
+	Total    money.Money   `json:"total"`
+
+	// AmountPaid is net of refunds. BalanceDue is the total less credits and
+	// payments, and is negative when money is owed back to the customer.
+	Discounts      []Discount      `json:"discounts,omitempty"`
+	Payments       []Payment       `json:"payments,omitempty"`
+	CreditNotes    []CreditNote    `json:"credit_notes,omitempty"`
+	Refunds        []Refund        `json:"refunds,omitempty"`
+	DunningNotices []DunningNotice `json:"dunning_notices,omitempty"`
+	AmountPaid     money.Money     `json:"amount_paid"`
+	AmountCredited money.Money     `json:"amount_credited"`
+	AmountRefunded money.Money     `json:"amount_refunded"`
+	BalanceDue     money.Money     `json:"balance_due"`
+	// Locale and the formatted amounts are filled in per request by Localize.
+	Locale              money.Locale `json:"locale,omitempty"`
+	FormattedTotal      string       `json:"formatted_total,omitempty"`
+	FormattedBalanceDue string       `json:"formatted_balance_due,omitempty"`
+	// moneyFormat is how amounts are encoded in the response, set per request by SetMoneyFormat.
+	moneyFormat money.JSONFormat
+}
+
+// SetMoneyFormat picks how the bill's amounts are encoded in an API response.
+// Workflow payloads never set it, so they always use the object form.
+func (b *Bill) SetMoneyFormat(format money.JSONFormat) {
+	b.moneyFormat = format
+}
+
+func (b Bill) MarshalJSON() ([]byte, error) {
+	type bill Bill
+
+	data, err := json.Marshal(bill(b))
+	if err != nil || b.moneyFormat == "" {
+		return data, err
+	}
+
+	return money.ConvertJSON(data, b.moneyFormat)
+}
+
+// Localize formats the bill's amounts for display in locale.
+func (b *Bill) Localize(locale money.Locale) {
+	b.Locale = locale
+	b.FormattedTotal = b.Total.Format(locale)
+	b.FormattedBalanceDue = b.BalanceDue.Format(locale)
+
+	for i := range b.LineItems {
+		b.LineItems[i].FormattedAmount = b.LineItems[i].Amount.Format(locale)
+	}
+
+	for i := range b.TaxLines {
+		b.TaxLines[i].FormattedAmount = b.TaxLines[i].Amount.Format(locale)
+	}
+
+	for i := range b.CreditNotes {
+		b.CreditNotes[i].FormattedTotal = b.CreditNotes[i].Total.Format(locale)
+
+		for j := range b.CreditNotes[i].LineItems {
+			b.CreditNotes[i].LineItems[j].FormattedAmount = b.CreditNotes[i].LineItems[j].Amount.Format(locale)
+		}
+	}
 }
 
+type LineItemType string
+
+const (
+	LineItemTypeCharge LineItemType = "CHARGE"
+	LineItemTypeFXFee  LineItemType = "FX_FEE"
+	LineItemTypeUsage  LineItemType = "USAGE"
+	// LineItemTypeRecurring is a subscription's fixed charge for the period.
+	LineItemTypeRecurring LineItemType = "RECURRING"
+	// LineItemTypeCredit is a negative line item on a credit note.
+	LineItemTypeCredit LineItemType = "CREDIT"
+	// LineItemTypeDiscount is a coupon's discount, added when the bill closes.
+	LineItemTypeDiscount LineItemType = "DISCOUNT"
+)
+
 type LineItem struct {
-	ID        string      `json:"id"`
-	Amount    money.Money `json:"amount"`
-	CreatedAt time.Time   `json:"created_at"`
+	ID         string       `json:"id"`
+	Type       LineItemType `json:"type,omitempty"`
+	Amount     money.Money  `json:"amount"`
+	CreatedAt  time.Time    `json:"created_at"`
+	Conversion *Conversion  `json:"conversion,omitempty"`
+	// RelatedItemID links a fee to the line item it was charged for.
+	RelatedItemID string `json:"related_item_id,omitempty"`
+	// UnitPrice and Quantity are set for usage charges. Their exact product is
+	// added to the bill, while Amount only shows it rounded.
+	UnitPrice *money.Precise `json:"unit_price,omitempty"`
+	Quantity  int64          `json:"quantity,omitempty"`
+	// TaxCode selects the tax rates of the customer's jurisdiction that apply.
+	TaxCode tax.Code `json:"tax_code,omitempty"`
+
+	Void *LineItemVoid `json:"void,omitempty"`
+
+	FormattedAmount string `json:"formatted_amount,omitempty"`
+}
+
+// LineItemVoid records who voided a line item and why.
+type LineItemVoid struct {
+	Reason   string    `json:"reason"`
+	VoidedBy string    `json:"voided_by"`
+	VoidedAt time.Time `json:"voided_at"`
+}
+
+// ExactAmount returns the unrounded amount the line item adds to the bill.
+func (li LineItem) ExactAmount() money.Precise {
+	if li.UnitPrice != nil {
+		return li.UnitPrice.MulInt(li.Quantity)
+	}
+
+	return li.Amount.Precise()
+}
+
+// Conversion records how a line item submitted in another currency
+// was converted to the bill currency. When a markup was folded into the rate,
+// Rate is the applied rate and MidRate the market rate it was derived from.
+type Conversion struct {
+	OriginalAmount   money.Money      `json:"original_amount"`
+	OriginalCurrency money.Currency   `json:"original_currency"`
+	Rate             decimal.Decimal  `json:"rate"`
+	RateEffectiveAt  time.Time        `json:"rate_effective_at"`
+	MidRate          *decimal.Decimal `json:"mid_rate,omitempty"`
+	Markup           *money.Money     `json:"markup,omitempty"`
+}
+
+type PaymentMethod string
+
+const (
+	PaymentMethodCard         PaymentMethod = "CARD"
+	PaymentMethodBankTransfer PaymentMethod = "BANK_TRANSFER"
+	PaymentMethodCash         PaymentMethod = "CASH"
+	PaymentMethodCheck        PaymentMethod = "CHECK"
+	PaymentMethodOther        PaymentMethod = "OTHER"
+)
+
+func (m PaymentMethod) IsValid() bool {
+	switch m {
+	case PaymentMethodCard, PaymentMethodBankTransfer, PaymentMethodCash, PaymentMethodCheck, PaymentMethodOther:
+		return true
+	}
+
+	return false
+}
+
+// Payment is a payment applied to a bill. Amount is in the bill currency;
+// payments received in another currency keep the original amount in Conversion.
+type Payment struct {
+	ID         string        `json:"id"`
+	Amount     money.Money   `json:"amount"`
+	Method     PaymentMethod `json:"method"`
+	Reference  string        `json:"reference,omitempty"`
+	ReceivedAt time.Time     `json:"received_at"`
+	Conversion *Conversion   `json:"conversion,omitempty"`
+}
+
+// CreditNote credits a closed bill with negative line items. Number counts the
+// bill's credit notes, e.g. "CN-<bill id>-001". RelatedItemID on a line item
+// optionally names the bill's line item being credited.
+type CreditNote struct {
+	ID             string      `json:"id"`
+	Number         string      `json:"number"`
+	BillID         string      `json:"bill_id"`
+	Reason         string      `json:"reason"`
+	LineItems      []LineItem  `json:"line_items"`
+	Total          money.Money `json:"total"`
+	IssuedAt       time.Time   `json:"issued_at"`
+	FormattedTotal string      `json:"formatted_total,omitempty"`
+}
+
+type IssueCreditNoteRequest struct {
+	ID        string     `json:"id"`
+	Reason    string     `json:"reason"`
+	LineItems []LineItem `json:"line_items"`
+	IssuedAt  time.Time  `json:"issued_at"`
+}
+
+// Refund records money returned to the customer, in the bill currency.
+type Refund struct {
+	ID         string        `json:"id"`
+	Amount     money.Money   `json:"amount"`
+	Method     PaymentMethod `json:"method"`
+	Reference  string        `json:"reference,omitempty"`
+	Reason     string        `json:"reason,omitempty"`
+	RefundedAt time.Time     `json:"refunded_at"`
+}
+
+// Coupon takes either PercentOff or AmountOff off the bills it is redeemed
+// against. Redeemed for a subscription, it applies to its next Periods bills,
+// or all of them when Periods is zero. A coupon with a CustomerID is attached
+// to that customer: only they can redeem it, and it is redeemed against each
+// bill they open, up to Periods bills. Redemptions only keeps the latest
+// redemptions, Redeemed and BillsRedeemed count all of them.
+type Coupon struct {
+	Code          string           `json:"code"`
+	PercentOff    *decimal.Decimal `json:"percent_off,omitempty"`
+	AmountOff     *money.Money     `json:"amount_off,omitempty"`
+	Periods       int              `json:"periods,omitempty"`
+	ExpiresAt     *time.Time       `json:"expires_at,omitempty"`
+	CustomerID    int              `json:"customer_id,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	Redeemed      int              `json:"redeemed"`
+	BillsRedeemed int              `json:"bills_redeemed"`
+	Redemptions   []Redemption     `json:"redemptions,omitempty"`
+}
+
+type Redemption struct {
+	CustomerID     int       `json:"customer_id"`
+	BillID         string    `json:"bill_id,omitempty"`
+	SubscriptionID string    `json:"subscription_id,omitempty"`
+	RedeemedAt     time.Time `json:"redeemed_at"`
+}
+
+// Discount is a coupon redeemed against a bill. Percentages are taken off the
+// bill's subtotal, fixed amounts are taken off in turn until nothing is left.
+// Periods is only used by subscriptions.
+type Discount struct {
+	CouponCode string           `json:"coupon_code"`
+	PercentOff *decimal.Decimal `json:"percent_off,omitempty"`
+	AmountOff  *money.Money     `json:"amount_off,omitempty"`
+	Periods    int              `json:"periods,omitempty"`
+	AppliedAt  time.Time        `json:"applied_at"`
+}
+
+// DunningNotice is a reminder sent for an unpaid bill. The Final one is the last.
+type DunningNotice struct {
+	Step   int       `json:"step"`
+	Final  bool      `json:"final,omitempty"`
+	DueAt  time.Time `json:"due_at"`
+	SentAt time.Time `json:"sent_at"`
+}
+
+// AddLineItemsRequest adds line items that must be accepted together.
+// Requests repeating an IdempotencyKey are not applied again; Fingerprint
+// identifies the original request so a key reused for another one is rejected.
+type AddLineItemsRequest struct {
+	IdempotencyKey string     `json:"idempotency_key,omitempty"`
+	Fingerprint    string     `json:"fingerprint,omitempty"`
+	LineItems      []LineItem `json:"line_items"`
+}
+
+type VoidLineItemRequest struct {
+	LineItemID string    `json:"line_item_id"`
+	Reason     string    `json:"reason"`
+	VoidedBy   string    `json:"voided_by"`
+	VoidedAt   time.Time `json:"voided_at"`
+}
+
+// StatusChange records a transition of the bill's status.
+type StatusChange struct {
+	From      BillStatus `json:"from"`
+	To        BillStatus `json:"to"`
+	ChangedAt time.Time  `json:"changed_at"`
+	Reason    string     `json:"reason,omitempty"`
+}
+
+// TransitionBillRequest moves a bill to Status. DueAt only applies when
+// finalizing, and defaults to DefaultPaymentTerms after finalization.
+type TransitionBillRequest struct {
+	Status    BillStatus `json:"status"`
+	Reason    string     `json:"reason,omitempty"`
+	DueAt     *time.Time `json:"due_at,omitempty"`
+	ChangedAt time.Time  `json:"changed_at"`
 }
 
 type CloseBillSignal struct {
 	ClosedAt time.Time `json:"closed_at"`
 }
+
+type SubscriptionStatus string
+
+const (
+	SubscriptionStatusActive    SubscriptionStatus = "ACTIVE"
+	SubscriptionStatusPaused    SubscriptionStatus = "PAUSED"
+	SubscriptionStatusCancelled SubscriptionStatus = "CANCELLED"
+)
+
+// Subscription opens a bill for each billing period until it is cancelled.
+// A paused subscription opens no new bills, the current one still closes at its period end.
+type Subscription struct {
+	ID              string             `json:"id"`
+	CustomerID      int                `json:"customer_id"`
+	Currency        money.Currency     `json:"currency"`
+	Period          BillingPeriod      `json:"period"`
+	RecurringCharge *money.Money       `json:"recurring_charge,omitempty"`
+	Status          SubscriptionStatus `json:"status"`
+	CreatedAt       time.Time          `json:"created_at"`
+	CancelledAt     *time.Time         `json:"cancelled_at,omitempty"`
+	CurrentBillID   string             `json:"current_bill_id,omitempty"`
+	PeriodsBilled   int                `json:"periods_billed"`
+	// NextPeriodStart keeps custom periods back to back across bills.
+	NextPeriodStart time.Time `json:"next_period_start,omitempty"`
+	// Discount applies to the next DiscountPeriods bills, or all of them when zero.
+	Discount        *Discount `json:"discount,omitempty"`
+	DiscountPeriods int       `json:"discount_periods,omitempty"`
+}
@@ -1,18 +1,135 @@
 package workflow
 
-import "time"
+import (
+	"fmt"
+	"time"
 
-func calculateTimeUntilNextMonth() time.Duration {
-	now := time.Now().UTC()
-	currentYear, currentMonth, _ := now.Date()
-	nextMonth := currentMonth + 1
-	nextYear := currentYear
+	// only a fallback for workers without a system tz database: Go prefers the
+	// system zoneinfo, and boundaries are recomputed on replay, so all workers
+	// should load the same zone data, e.g. by pointing ZONEINFO at one zoneinfo.zip
+	_ "time/tzdata"
+)
 
-	if nextMonth > 12 {
-		nextMonth = 1
-		nextYear++
+// Bounds returns the billing period containing now. Calendar periods are aligned
+// in the period's timezone: days at midnight, weeks on the anchor weekday and
+// months, quarters and years on the anchor day, clamped to short months.
+// Custom periods start at Start, or at now when it is not set.
+func (p BillingPeriod) Bounds(now time.Time) (start, end time.Time, err error) {
+	if err = p.Validate(); err != nil {
+		return
 	}
 
-	nextMonthStart := time.Date(nextYear, nextMonth, 1, 0, 0, 0, 0, time.UTC)
-	return nextMonthStart.Sub(now)
+	location, err := time.LoadLocation(p.timezone())
+	if err != nil {
+		return
+	}
+
+	local := now.In(location)
+	year, month, day := local.Date()
+
+	switch p.interval() {
+	case BillingIntervalDaily:
+		start = time.Date(year, month, day, 0, 0, 0, 0, location)
+		end = start.AddDate(0, 0, 1)
+	case BillingIntervalWeekly:
+		anchor := time.Weekday(p.anchorDay() % 7)
+		offset := (int(local.Weekday()) - int(anchor) + 7) % 7
+		start = time.Date(year, month, day-offset, 0, 0, 0, 0, location)
+		end = start.AddDate(0, 0, 7)
+	case BillingIntervalCustom:
+		start = now
+		if !p.Start.IsZero() && !p.Start.After(now) {
+			start = p.Start
+		}
+
+		end = start.Add(p.Duration)
+	default:
+		months := p.months()
+
+		// first month of the calendar month, quarter or year containing now
+		first := time.Month((int(month)-1)/months*months + 1)
+
+		start = anchorDate(year, first, p.anchorDay(), location)
+		if start.After(local) {
+			start = anchorDate(year, first-time.Month(months), p.anchorDay(), location)
+		}
+
+		startYear, startMonth, _ := start.Date()
+		end = anchorDate(startYear, startMonth+time.Month(months), p.anchorDay(), location)
+	}
+
+	return start.UTC(), end.UTC(), nil
+}
+
+// anchorDate returns the anchor day of a month, or its last day if shorter.
+// Months outside 1-12 are normalized, as in time.Date.
+func anchorDate(year int, month time.Month, anchorDay int, location *time.Location) time.Time {
+	daysInMonth := time.Date(year, month+1, 0, 0, 0, 0, 0, location).Day()
+	if anchorDay > daysInMonth {
+		anchorDay = daysInMonth
+	}
+
+	return time.Date(year, month, anchorDay, 0, 0, 0, 0, location)
+}
+
+func (p BillingPeriod) Validate() error {
+	switch p.interval() {
+	case BillingIntervalDaily:
+	case BillingIntervalWeekly:
+		if p.AnchorDay < 0 || p.AnchorDay > 7 {
+			return fmt.Errorf("weekly anchor day must be between 1 (Monday) and 7 (Sunday)")
+		}
+	case BillingIntervalMonthly, BillingIntervalQuarterly, BillingIntervalAnnual:
+		if p.AnchorDay < 0 || p.AnchorDay > 31 {
+			return fmt.Errorf("anchor day must be between 1 and 31")
+		}
+	case BillingIntervalCustom:
+		if p.Duration < time.Hour {
+			return fmt.Errorf("custom billing periods must be at least an hour")
+		}
+	default:
+		return fmt.Errorf("invalid billing interval: %s", p.Interval)
+	}
+
+	if _, err := time.LoadLocation(p.timezone()); err != nil {
+		return fmt.Errorf("invalid timezone: %s", p.Timezone)
+	}
+
+	return nil
+}
+
+// interval defaults to monthly, which is how bills were closed before periods were configurable.
+func (p BillingPeriod) interval() BillingInterval {
+	if p.Interval == "" {
+		return BillingIntervalMonthly
+	}
+
+	return p.Interval
+}
+
+func (p BillingPeriod) anchorDay() int {
+	if p.AnchorDay == 0 {
+		return 1
+	}
+
+	return p.AnchorDay
+}
+
+func (p BillingPeriod) timezone() string {
+	if p.Timezone == "" {
+		return "UTC"
+	}
+
+	return p.Timezone
+}
+
+func (p BillingPeriod) months() int {
+	switch p.interval() {
+	case BillingIntervalQuarterly:
+		return 3
+	case BillingIntervalAnnual:
+		return 12
+	default:
+		return 1
+	}
 }
@@ -0,0 +1,63 @@
+package workflow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_BillingPeriod_Bounds(t *testing.T) {
+	tbilisi, err := time.LoadLocation("Asia/Tbilisi")
+	require.NoError(t, err)
+
+	utc := func(year int, month time.Month, day, hour int) time.Time {
+		return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	local := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, tbilisi).UTC()
+	}
+
+	tests := []struct {
+		name       string
+		period     BillingPeriod
+		now        time.Time
+		start, end time.Time
+	}{
+		{"zero value is a UTC calendar month", BillingPeriod{}, utc(2025, 1, 15, 10), utc(2025, 1, 1, 0), utc(2025, 2, 1, 0)},
+		{"daily", BillingPeriod{Interval: BillingIntervalDaily}, utc(2025, 1, 15, 10), utc(2025, 1, 15, 0), utc(2025, 1, 16, 0)},
+		{"daily in customer timezone", BillingPeriod{Interval: BillingIntervalDaily, Timezone: "Asia/Tbilisi"}, utc(2025, 1, 15, 22), local(2025, 1, 16), local(2025, 1, 17)},
+		{"weekly defaults to monday", BillingPeriod{Interval: BillingIntervalWeekly}, utc(2025, 1, 15, 10), utc(2025, 1, 13, 0), utc(2025, 1, 20, 0)},
+		{"weekly on sunday", BillingPeriod{Interval: BillingIntervalWeekly, AnchorDay: 7}, utc(2025, 1, 15, 10), utc(2025, 1, 12, 0), utc(2025, 1, 19, 0)},
+		{"monthly anchor before now", BillingPeriod{Interval: BillingIntervalMonthly, AnchorDay: 10}, utc(2025, 1, 15, 10), utc(2025, 1, 10, 0), utc(2025, 2, 10, 0)},
+		{"monthly anchor after now", BillingPeriod{Interval: BillingIntervalMonthly, AnchorDay: 20}, utc(2025, 1, 15, 10), utc(2024, 12, 20, 0), utc(2025, 1, 20, 0)},
+		{"monthly anchor clamped to short month", BillingPeriod{Interval: BillingIntervalMonthly, AnchorDay: 31}, utc(2025, 2, 15, 10), utc(2025, 1, 31, 0), utc(2025, 2, 28, 0)},
+		{"monthly in customer timezone", BillingPeriod{Interval: BillingIntervalMonthly, Timezone: "Asia/Tbilisi"}, utc(2025, 1, 31, 21), local(2025, 2, 1), local(2025, 3, 1)},
+		{"quarterly", BillingPeriod{Interval: BillingIntervalQuarterly}, utc(2025, 5, 15, 10), utc(2025, 4, 1, 0), utc(2025, 7, 1, 0)},
+		{"quarterly anchor after now", BillingPeriod{Interval: BillingIntervalQuarterly, AnchorDay: 15}, utc(2025, 1, 10, 0), utc(2024, 10, 15, 0), utc(2025, 1, 15, 0)},
+		{"annual", BillingPeriod{Interval: BillingIntervalAnnual}, utc(2025, 5, 15, 10), utc(2025, 1, 1, 0), utc(2026, 1, 1, 0)},
+		{"custom", BillingPeriod{Interval: BillingIntervalCustom, Duration: 36 * time.Hour}, utc(2025, 1, 15, 10), utc(2025, 1, 15, 10), utc(2025, 1, 16, 22)},
+	}
+
+	for _, tt := range tests {
+		start, end, err := tt.period.Bounds(tt.now)
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, tt.start, start, tt.name)
+		assert.Equal(t, tt.end, end, tt.name)
+	}
+}
+
+func Test_BillingPeriod_Validate_RejectsInvalidPeriods(t *testing.T) {
+	for _, period := range []BillingPeriod{
+		{Interval: "HOURLY"},
+		{Interval: BillingIntervalWeekly, AnchorDay: 8},
+		{Interval: BillingIntervalMonthly, AnchorDay: 32},
+		{Interval: BillingIntervalCustom},
+		{Interval: BillingIntervalCustom, Duration: time.Minute},
+		{Timezone: "Mars/Olympus_Mons"},
+	} {
+		assert.Error(t, period.Validate(), period)
+	}
+}
@@ -4,25 +4,57 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/sunneydev/pave-billing-api/bills/config"
 	"github.com/sunneydev/pave-billing-api/bills/money"
+	"github.com/sunneydev/pave-billing-api/bills/tax"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 )
 
-func BillingPeriodWorkflow(ctx workflow.Context, billID string, customerID int, currency money.Currency) error {
+// BillingPeriodWorkflow keeps a bill open until it is closed or its billing period ends,
+// then follows it through the rest of its lifecycle until it is settled.
+// Workflows started before periods were configurable have a zero period, which is a calendar month in UTC.
+// Draft bills take line items like open ones, but have to be opened before they can be closed.
+// A draft still unopened when its period ends is closed as soon as it is opened.
+func BillingPeriodWorkflow(ctx workflow.Context, billID string, customerID int, currency money.Currency, period BillingPeriod, draft bool) error {
 	logger := workflow.GetLogger(ctx)
 
+	now := workflow.Now(ctx).UTC()
+
+	periodStart, periodEnd, err := period.Bounds(now)
+	if err != nil {
+		return fmt.Errorf("invalid billing period: %v", err)
+	}
+
 	bill := &Bill{
-		ID:         billID,
-		CustomerID: customerID,
-		Currency:   currency,
-		Status:     BillStatusOpen,
-		CreatedAt:  workflow.Now(ctx).UTC(),
-		LineItems:  make([]LineItem, 0),
-		Total:      money.New(money.ZeroAmount(), currency),
+		ID:             billID,
+		CustomerID:     customerID,
+		Currency:       currency,
+		Status:         BillStatusOpen,
+		CreatedAt:      now,
+		Period:         period,
+		PeriodStart:    periodStart,
+		PeriodEnd:      periodEnd,
+		LineItems:      make([]LineItem, 0),
+		Subtotal:       money.NewPrecise(money.ZeroAmount(), currency),
+		Tax:            money.New(money.ZeroAmount(), currency),
+		Total:          money.New(money.ZeroAmount(), currency),
+		AmountPaid:     money.New(money.ZeroAmount(), currency),
+		AmountCredited: money.New(money.ZeroAmount(), currency),
+		AmountRefunded: money.New(money.ZeroAmount(), currency),
+		BalanceDue:     money.New(money.ZeroAmount(), currency),
+	}
+
+	if draft {
+		bill.Status = BillStatusDraft
+	}
+
+	parent := workflow.GetInfo(ctx).ParentWorkflowExecution
+	if parent != nil {
+		bill.SubscriptionID = parent.ID
 	}
 
-	err := workflow.SetQueryHandler(ctx, QueryGetBill, func() (*Bill, error) {
+	err = workflow.SetQueryHandler(ctx, QueryGetBill, func() (*Bill, error) {
 		return bill, nil
 	})
 
@@ -30,76 +62,390 @@ func BillingPeriodWorkflow(ctx workflow.Context, billID string, customerID int,
 		return fmt.Errorf("failed to register query handler: %v", err)
 	}
 
-	billingPeriodTimeout := workflow.NewTimer(ctx, calculateTimeUntilNextMonth())
-	addItemChan := workflow.GetSignalChannel(ctx, SignalAddLineItem)
-	closeChan := workflow.GetSignalChannel(ctx, SignalCloseBill)
+	// idempotency keys are remembered for the life of the bill,
+	// so a retried request returns the items it created the first time
+	requests := make(map[string]string)
 
-	for {
-		selector := workflow.NewSelector(ctx)
+	err = workflow.SetUpdateHandlerWithOptions(ctx, UpdateAddLineItems,
+		func(ctx workflow.Context, request AddLineItemsRequest) (*Bill, error) {
+			if _, ok := requests[request.IdempotencyKey]; ok {
+				logger.Info("replayed line items request", "bill_id", bill.ID, "idempotency_key", request.IdempotencyKey)
+				return bill, nil
+			}
+
+			if err := bill.addLineItems(request.LineItems); err != nil {
+				return nil, err
+			}
+
+			if request.IdempotencyKey != "" {
+				requests[request.IdempotencyKey] = request.Fingerprint
+			}
 
-		selector.AddReceive(addItemChan, func(ch workflow.ReceiveChannel, more bool) {
-			var lineItem LineItem
-			ch.Receive(ctx, &lineItem)
+			logger.Info("added line items", "bill_id", bill.ID, "count", len(request.LineItems))
 
-			if bill.Status == BillStatusClosed {
-				logger.Warn("ignoring line item for closed bill", "bill_id", bill.ID)
-				return
+			return bill, nil
+		},
+		workflow.UpdateHandlerOptions{Validator: func(request AddLineItemsRequest) error {
+			fingerprint, ok := requests[request.IdempotencyKey]
+			if !ok {
+				return bill.validateLineItems(request.LineItems)
 			}
 
-			bill.LineItems = append(bill.LineItems, lineItem)
+			if fingerprint != request.Fingerprint {
+				return fmt.Errorf("idempotency key %q was already used for a different request", request.IdempotencyKey)
+			}
+
+			return nil
+		}},
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to register update handler: %v", err)
+	}
+
+	err = workflow.SetUpdateHandlerWithOptions(ctx, UpdateCloseBill,
+		func(ctx workflow.Context, request CloseBillSignal) (*Bill, error) {
+			closeBill(ctx, bill, request.ClosedAt)
+			logger.Info("closed bill", "bill_id", bill.ID)
+
+			return bill, nil
+		},
+		workflow.UpdateHandlerOptions{Validator: func(request CloseBillSignal) error {
+			return bill.validateTransition(TransitionBillRequest{Status: BillStatusClosed, ChangedAt: request.ClosedAt})
+		}},
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to register update handler: %v", err)
+	}
+
+	err = workflow.SetUpdateHandlerWithOptions(ctx, UpdateTransitionBill,
+		func(ctx workflow.Context, request TransitionBillRequest) (*Bill, error) {
+			from := bill.Status
+			if request.Status == BillStatusClosed {
+				closeBill(ctx, bill, request.ChangedAt)
+			} else if err := bill.transition(request); err != nil {
+				return nil, err
+			}
+
+			logger.Info("changed bill status", "bill_id", bill.ID, "from", from, "to", bill.Status)
+
+			return bill, nil
+		},
+		workflow.UpdateHandlerOptions{Validator: bill.validateTransition},
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to register update handler: %v", err)
+	}
+
+	err = workflow.SetUpdateHandlerWithOptions(ctx, UpdateVoidLineItem,
+		func(ctx workflow.Context, request VoidLineItemRequest) (*Bill, error) {
+			if err := bill.voidLineItem(request); err != nil {
+				return nil, err
+			}
 
-			// error occurs only if currencies are different,
-			// which are we already handle in service.go
-			newTotal, err := bill.Total.Add(lineItem.Amount)
+			logger.Info("voided line item", "bill_id", bill.ID, "line_item_id", request.LineItemID, "voided_by", request.VoidedBy)
+
+			return bill, nil
+		},
+		workflow.UpdateHandlerOptions{Validator: bill.validateVoid},
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to register update handler: %v", err)
+	}
+
+	err = workflow.SetUpdateHandlerWithOptions(ctx, UpdateApplyPayment,
+		func(ctx workflow.Context, payment Payment) (*Bill, error) {
+			if err := bill.applyPayment(payment, workflow.Now(ctx).UTC()); err != nil {
+				return nil, err
+			}
+
+			logger.Info("applied payment", "bill_id", bill.ID, "payment_id", payment.ID, "status", bill.Status)
+
+			return bill, nil
+		},
+		workflow.UpdateHandlerOptions{Validator: bill.validatePayment},
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to register update handler: %v", err)
+	}
+
+	err = workflow.SetUpdateHandlerWithOptions(ctx, UpdateIssueCreditNote,
+		func(ctx workflow.Context, request IssueCreditNoteRequest) (*Bill, error) {
+			creditNote, err := bill.issueCreditNote(request)
 			if err != nil {
-				logger.Error("failed to add line item amount", "error", err)
-				return
+				return nil, err
 			}
 
-			bill.Total = newTotal
+			logger.Info("issued credit note", "bill_id", bill.ID, "credit_note", creditNote.Number)
 
-			logger.Info("added line item", "bill_id", bill.ID)
-		})
+			// the update returns without waiting for the email
+			notice := *creditNote
+			workflow.Go(ctx, func(ctx workflow.Context) {
+				sendCreditNoteEmail(ctx, bill, notice)
+			})
 
-		selector.AddReceive(closeChan, func(ch workflow.ReceiveChannel, more bool) {
-			var signal CloseBillSignal
-			ch.Receive(ctx, &signal)
+			return bill, nil
+		},
+		workflow.UpdateHandlerOptions{Validator: bill.validateCreditNote},
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to register update handler: %v", err)
+	}
 
-			if bill.Status == BillStatusClosed {
-				logger.Warn("tried to close already closed bill", "bill_id", bill.ID)
-				return
+	err = workflow.SetUpdateHandlerWithOptions(ctx, UpdateRecordRefund,
+		func(ctx workflow.Context, refund Refund) (*Bill, error) {
+			if err := bill.recordRefund(refund); err != nil {
+				return nil, err
 			}
 
-			bill.Status = BillStatusClosed
-			bill.ClosedAt = &signal.ClosedAt
-			logger.Info("closed bill", "bill_id", bill.ID)
+			logger.Info("recorded refund", "bill_id", bill.ID, "refund_id", refund.ID)
 
-			sendEmailNotification(ctx, bill)
-		})
+			return bill, nil
+		},
+		workflow.UpdateHandlerOptions{Validator: bill.validateRefund},
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to register update handler: %v", err)
+	}
 
-		selector.AddFuture(billingPeriodTimeout, func(f workflow.Future) {
-			if bill.Status == BillStatusClosed {
-				return
+	err = workflow.SetUpdateHandlerWithOptions(ctx, UpdateApplyDiscount,
+		func(ctx workflow.Context, discount Discount) (*Bill, error) {
+			if err := bill.addDiscount(discount); err != nil {
+				return nil, err
 			}
 
-			f.Get(ctx, nil)
+			logger.Info("applied discount", "bill_id", bill.ID, "coupon", discount.CouponCode)
+
+			return bill, nil
+		},
+		workflow.UpdateHandlerOptions{Validator: bill.validateDiscount},
+	)
+
+	if err != nil {
+		return fmt.Errorf("failed to register update handler: %v", err)
+	}
+
+	// signals are still handled for clients and workflows from before the update handlers,
+	// and carry the recurring charge and discount from a parent subscription
+	addItemChan := workflow.GetSignalChannel(ctx, SignalAddLineItem)
+	closeChan := workflow.GetSignalChannel(ctx, SignalCloseBill)
+	discountChan := workflow.GetSignalChannel(ctx, SignalApplyDiscount)
+
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		for {
+			selector := workflow.NewSelector(ctx)
+
+			selector.AddReceive(addItemChan, func(ch workflow.ReceiveChannel, more bool) {
+				var lineItem LineItem
+				ch.Receive(ctx, &lineItem)
+
+				if err := bill.addLineItems([]LineItem{lineItem}); err != nil {
+					logger.Warn("ignoring line item", "bill_id", bill.ID, "error", err)
+					return
+				}
+
+				logger.Info("added line item", "bill_id", bill.ID)
+			})
+
+			selector.AddReceive(discountChan, func(ch workflow.ReceiveChannel, more bool) {
+				var discount Discount
+				ch.Receive(ctx, &discount)
 
-			now := workflow.Now(ctx).UTC()
-			bill.Status = BillStatusClosed
-			bill.ClosedAt = &now
-			logger.Info("auto-closed bill due to billing period end", "bill_id", bill.ID)
+				if err := bill.addDiscount(discount); err != nil {
+					logger.Warn("ignoring discount", "bill_id", bill.ID, "error", err)
+					return
+				}
 
-			sendEmailNotification(ctx, bill)
+				logger.Info("applied discount", "bill_id", bill.ID, "coupon", discount.CouponCode)
+			})
+
+			selector.AddReceive(closeChan, func(ch workflow.ReceiveChannel, more bool) {
+				var signal CloseBillSignal
+				ch.Receive(ctx, &signal)
+
+				if err := bill.validateTransition(TransitionBillRequest{Status: BillStatusClosed, ChangedAt: signal.ClosedAt}); err != nil {
+					logger.Warn("ignoring close signal", "bill_id", bill.ID, "error", err)
+					return
+				}
+
+				closeBill(ctx, bill, signal.ClosedAt)
+				logger.Info("closed bill", "bill_id", bill.ID)
+			})
+
+			selector.Select(ctx)
+		}
+	})
+
+	// workflows started before the period wait was an AwaitWithTimeout waited on a plain timer
+	if workflow.GetVersion(ctx, ChangeAwaitPeriodEnd, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+		periodEnded := workflow.NewTimer(ctx, periodEnd.Sub(now))
+		err = workflow.Await(ctx, func() bool {
+			return periodEnded.IsReady() || !bill.Status.IsEditable()
+		})
+	} else {
+		_, err = workflow.AwaitWithTimeout(ctx, periodEnd.Sub(now), func() bool {
+			return !bill.Status.IsEditable()
 		})
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if bill.Status == BillStatusDraft {
+		// drafts are only closed once they have been opened
+		logger.Info("billing period ended before the draft was opened", "bill_id", bill.ID)
+
+		if err = workflow.Await(ctx, func() bool { return bill.Status != BillStatusDraft }); err != nil {
+			return err
+		}
+	}
+
+	closedAt := workflow.Now(ctx).UTC()
+	if bill.validateTransition(TransitionBillRequest{Status: BillStatusClosed, ChangedAt: closedAt}) == nil {
+		closeBill(ctx, bill, closedAt)
+		logger.Info("auto-closed bill due to billing period end", "bill_id", bill.ID)
+	}
+
+	sendEmailNotification(ctx, bill)
+
+	if parent != nil {
+		// the subscription opens the next bill once this one has closed
+		err = workflow.SignalExternalWorkflow(ctx, parent.ID, "", SignalBillClosed, bill.ID).Get(ctx, nil)
+		if err != nil {
+			logger.Warn("failed to notify subscription", "bill_id", bill.ID, "subscription_id", parent.ID, "error", err)
+		}
+	}
+
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		runDunning(ctx, bill)
+	})
+
+	// workflows started before bills were settled in the workflow completed once the email was sent
+	if workflow.GetVersion(ctx, ChangeAwaitSettlement, workflow.DefaultVersion, 1) != workflow.DefaultVersion {
+		var retention time.Duration
+		err = workflow.SideEffect(ctx, func(ctx workflow.Context) interface{} {
+			return config.BillRetention
+		}).Get(&retention)
+
+		if err == nil {
+			err = awaitSettlement(ctx, bill, retention)
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return workflow.Await(ctx, func() bool {
+		return workflow.AllHandlersFinished(ctx)
+	})
+}
+
+// awaitSettlement keeps a closed bill for retention, so it can still be paid,
+// credited and refunded, marking it overdue when its due date passes and
+// uncollectible if it is still owed at the end. Bills with nothing left to
+// pay are never marked overdue or uncollectible. It returns as soon as the
+// bill is final and no refund is owed to the customer.
+func awaitSettlement(ctx workflow.Context, bill *Bill, retention time.Duration) error {
+	logger := workflow.GetLogger(ctx)
+	retainUntil := bill.ClosedAt.Add(retention)
+
+	settled := func() bool {
+		return bill.Status.IsFinal() && !bill.BalanceDue.IsNegative()
+	}
 
-		selector.Select(ctx)
+	for {
+		if settled() {
+			logger.Info("bill settled", "bill_id", bill.ID, "status", bill.Status)
+			return nil
+		}
+
+		now := workflow.Now(ctx).UTC()
+
+		if !now.Before(retainUntil) {
+			if bill.Status.IsOwed() && bill.BalanceDue.IsPositive() {
+				bill.setStatus(BillStatusUncollectible, now, "unpaid after retention period")
+				logger.Info("marked bill uncollectible", "bill_id", bill.ID)
+			}
+
+			return nil
+		}
+
+		deadline := retainUntil
+		awaitingDueDate := bill.DueAt != nil && bill.BalanceDue.IsPositive() &&
+			(bill.Status == BillStatusFinalized || bill.Status == BillStatusPartiallyPaid)
+		if awaitingDueDate && bill.DueAt.Before(deadline) {
+			deadline = *bill.DueAt
+		}
+
+		if awaitingDueDate && !now.Before(*bill.DueAt) {
+			bill.setStatus(BillStatusOverdue, now, "")
+			logger.Info("bill is overdue", "bill_id", bill.ID)
+			continue
+		}
+
+		status := bill.Status
+		if _, err := workflow.AwaitWithTimeout(ctx, deadline.Sub(now), func() bool {
+			return bill.Status != status || settled()
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+// closeBill closes the bill with the taxes of the customer's current tax profile,
+// which is recorded in the history so replays charge the same taxes.
+// Bills of workflows started before taxes were charged are closed without them.
+func closeBill(ctx workflow.Context, bill *Bill, closedAt time.Time) {
+	logger := workflow.GetLogger(ctx)
+
+	var profile tax.Profile
+
+	if workflow.GetVersion(ctx, ChangeTaxAtClose, workflow.DefaultVersion, 1) != workflow.DefaultVersion {
+		err := workflow.SideEffect(ctx, func(ctx workflow.Context) interface{} {
+			return config.CustomerTaxProfile(bill.CustomerID)
+		}).Get(&profile)
 
-		if bill.Status == BillStatusClosed {
-			break
+		if err != nil {
+			logger.Error("failed to load tax profile, closing bill without tax", "bill_id", bill.ID, "error", err)
+			profile = tax.Profile{}
 		}
 	}
 
-	return nil
+	if err := bill.close(closedAt, profile); err != nil {
+		logger.Error("failed to close bill cleanly", "bill_id", bill.ID, "error", err)
+	}
+}
+
+func sendCreditNoteEmail(ctx workflow.Context, bill *Bill, creditNote CreditNote) {
+	activityCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute * 5,
+		RetryPolicy: &temporal.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2.0,
+			MaximumInterval:    time.Minute * 5,
+			MaximumAttempts:    5,
+		},
+	})
+
+	details := CreditNoteDetails{Bill: bill, CreditNote: creditNote}
+
+	err := workflow.ExecuteActivity(activityCtx, SendCreditNoteEmail, details).Get(activityCtx, nil)
+	if err != nil {
+		workflow.GetLogger(ctx).Error("Failed to send credit note email",
+			"error_type", "EMAIL_SERVICE_ERROR",
+			"bill_id", bill.ID,
+			"customer_id", bill.CustomerID,
+			"credit_note", creditNote.Number,
+			"error", err)
+	}
 }
 
 func sendEmailNotification(ctx workflow.Context, bill *Bill) {
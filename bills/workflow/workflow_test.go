@@ -10,7 +10,9 @@ import (
 	"github.com/stretchr/testify/suite"
 	"github.com/sunneydev/pave-billing-api/bills/config"
 	"github.com/sunneydev/pave-billing-api/bills/money"
+	"github.com/sunneydev/pave-billing-api/bills/tax"
 	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
 )
 
 type BillingWorkflowTestSuite struct {
@@ -22,6 +24,66 @@ type BillingWorkflowTestSuite struct {
 
 func (s *BillingWorkflowTestSuite) SetupTest() {
 	s.env = s.NewTestWorkflowEnvironment()
+	s.env.RegisterActivity(SendBillClosedEmail)
+	s.env.RegisterActivity(SendDunningNotice)
+	s.env.RegisterActivity(SendCreditNoteEmail)
+}
+
+// convert mirrors Service.AddLineItem, which converts amounts to the bill currency before signalling.
+func (s *BillingWorkflowTestSuite) convert(amount money.Money, currency money.Currency, at time.Time) money.Money {
+	converted, err := amount.ConvertTo(currency, config.Rates, at)
+	s.Require().NoError(err)
+
+	return converted
+}
+
+// update sends the update named name after delay. The request is built when the update is
+// sent, with the workflow's current time. A rejection is stored in rejection, and fails the
+// test when rejection is nil. The result is copied into result, when given, since the test
+// environment returns the workflow's own state.
+func (s *BillingWorkflowTestSuite) update(name string, id string, delay time.Duration, request func(now time.Time) interface{}, result interface{}, rejection *error) {
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(name, id, &testsuite.TestUpdateCallback{
+			OnAccept: func() {},
+			OnReject: func(err error) {
+				if rejection == nil {
+					s.Fail("update should not be rejected", err)
+					return
+				}
+
+				*rejection = err
+			},
+			OnComplete: func(updated interface{}, err error) {
+				s.NoError(err)
+
+				if result != nil && err == nil {
+					data, err := json.Marshal(updated)
+					s.Require().NoError(err)
+					s.Require().NoError(json.Unmarshal(data, result))
+				}
+			},
+		}, request(s.env.Now().UTC()))
+	}, delay)
+}
+
+// constant builds the same request whenever it is sent.
+func constant(request interface{}) func(time.Time) interface{} {
+	return func(time.Time) interface{} {
+		return request
+	}
+}
+
+// assertClosed checks the workflow closed the bill. The tests query bills once their
+// workflow has completed, by when bills that were never paid have been written off.
+func (s *BillingWorkflowTestSuite) assertClosed(bill *Bill) {
+	s.Require().NotNil(bill.ClosedAt)
+
+	if bill.BalanceDue.IsPositive() {
+		s.Equal(BillStatusUncollectible, bill.Status)
+		s.Equal(BillStatusClosed, bill.StatusHistory[len(bill.StatusHistory)-1].From)
+	} else {
+		s.Equal(BillStatusClosed, bill.Status)
+	}
 }
 
 func (s *BillingWorkflowTestSuite) AfterTest(suiteName, testName string) {
@@ -37,7 +99,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_BasicExecution() {
 	customerID := 456
 	currency := money.USD
 
-	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency, BillingPeriod{}, false)
 
 	s.True(s.env.IsWorkflowCompleted())
 	s.NoError(s.env.GetWorkflowError())
@@ -50,7 +112,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_BasicExecution() {
 	s.Equal(billID, bill.ID)
 	s.Equal(customerID, bill.CustomerID)
 	s.Equal(currency, bill.Currency)
-	s.Equal(BillStatusClosed, bill.Status)
+	s.assertClosed(bill)
 	s.NotNil(bill.ClosedAt)
 	s.Len(bill.LineItems, 0)
 }
@@ -83,7 +145,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_AddLineItems() {
 		s.env.SignalWorkflow(SignalAddLineItem, item2)
 	}, time.Second*2)
 
-	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency, BillingPeriod{}, false)
 
 	s.True(s.env.IsWorkflowCompleted())
 	s.NoError(s.env.GetWorkflowError())
@@ -96,7 +158,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_AddLineItems() {
 	s.Equal(billID, bill.ID)
 	s.Equal(customerID, bill.CustomerID)
 	s.Equal(currency, bill.Currency)
-	s.Equal(BillStatusClosed, bill.Status)
+	s.assertClosed(bill)
 	s.NotNil(bill.ClosedAt)
 	s.Len(bill.LineItems, 2)
 
@@ -110,19 +172,20 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_GELCurrencyConvers
 	currency := money.USD
 
 	gelAmount, _ := money.NewFromString("100.00", money.GEL)
+	createdAt := time.Now().UTC()
 	gelItem := LineItem{
 		ID:        "gel-item",
-		Amount:    gelAmount,
-		CreatedAt: time.Now().UTC(),
+		Amount:    s.convert(gelAmount, currency, createdAt),
+		CreatedAt: createdAt,
 	}
 
-	expectedUsdAmount, _ := money.NewFromString("36.01", money.USD)
+	expectedUsdAmount, _ := money.NewFromString("36.00", money.USD)
 
 	s.env.RegisterDelayedCallback(func() {
 		s.env.SignalWorkflow(SignalAddLineItem, gelItem)
 	}, time.Second)
 
-	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency, BillingPeriod{}, false)
 
 	s.True(s.env.IsWorkflowCompleted())
 	s.NoError(s.env.GetWorkflowError())
@@ -141,6 +204,57 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_GELCurrencyConvers
 	s.Equal(expectedUsdAmount, bill.Total)
 }
 
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_KeepsConversionDetails() {
+	billID := "bill-123"
+	customerID := 456
+	currency := money.USD
+
+	gelAmount, _ := money.NewFromString("100.00", money.GEL)
+	createdAt := time.Now().UTC()
+
+	rate, err := config.Rates.Rate(money.GEL, currency, createdAt)
+	s.Require().NoError(err)
+
+	usdAmount, err := gelAmount.Convert(rate)
+	s.Require().NoError(err)
+
+	gelItem := LineItem{
+		ID:        "gel-item",
+		Amount:    usdAmount,
+		CreatedAt: createdAt,
+		Conversion: &Conversion{
+			OriginalAmount:   gelAmount,
+			OriginalCurrency: money.GEL,
+			Rate:             rate.Value,
+			RateEffectiveAt:  rate.EffectiveAt,
+		},
+	}
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(SignalAddLineItem, gelItem)
+	}, time.Second)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency, BillingPeriod{}, false)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	var bill *Bill
+	result, err := s.env.QueryWorkflow(QueryGetBill)
+	s.NoError(err)
+	s.NoError(result.Get(&bill))
+
+	s.Len(bill.LineItems, 1)
+	s.Require().NotNil(bill.LineItems[0].Conversion)
+
+	conversion := bill.LineItems[0].Conversion
+	s.Equal(gelAmount, conversion.OriginalAmount)
+	s.Equal(money.GEL, conversion.OriginalCurrency)
+	s.True(rate.Value.Equal(conversion.Rate))
+	s.True(rate.EffectiveAt.Equal(conversion.RateEffectiveAt))
+	s.Equal(usdAmount, bill.Total)
+}
+
 func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_CloseBill() {
 	billID := "bill-123"
 	customerID := 456
@@ -155,7 +269,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_CloseBill() {
 		s.env.SignalWorkflow(SignalCloseBill, closeSignal)
 	}, time.Second)
 
-	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency, BillingPeriod{}, false)
 
 	s.True(s.env.IsWorkflowCompleted())
 	s.NoError(s.env.GetWorkflowError())
@@ -168,7 +282,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_CloseBill() {
 	s.Equal(billID, bill.ID)
 	s.Equal(customerID, bill.CustomerID)
 	s.Equal(currency, bill.Currency)
-	s.Equal(BillStatusClosed, bill.Status)
+	s.assertClosed(bill)
 	s.NotNil(bill.ClosedAt)
 	s.Equal(closedAt, *bill.ClosedAt)
 }
@@ -210,7 +324,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_IgnoreItemsAfterCl
 		s.env.SignalWorkflow(SignalAddLineItem, item2)
 	}, time.Second*3)
 
-	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency, BillingPeriod{}, false)
 
 	s.True(s.env.IsWorkflowCompleted())
 	s.NoError(s.env.GetWorkflowError())
@@ -223,13 +337,15 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_IgnoreItemsAfterCl
 	s.Equal(billID, bill.ID)
 	s.Equal(customerID, bill.CustomerID)
 	s.Equal(currency, bill.Currency)
-	s.Equal(BillStatusClosed, bill.Status)
+	s.assertClosed(bill)
 	s.NotNil(bill.ClosedAt)
 	s.Len(bill.LineItems, 1)
 	s.Equal(item1.Amount, bill.Total)
 }
 
 func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_BillingPeriodTimeout() {
+	s.env.SetStartTime(time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC))
+
 	billID := "bill-123"
 	customerID := 456
 	currency := money.USD
@@ -255,7 +371,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_BillingPeriodTimeo
 		s.Nil(bill.ClosedAt)
 	}, time.Hour*24*15)
 
-	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency, BillingPeriod{}, false)
 
 	s.True(s.env.IsWorkflowCompleted())
 	s.NoError(s.env.GetWorkflowError())
@@ -265,7 +381,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_BillingPeriodTimeo
 	s.NoError(err)
 	s.NoError(result.Get(&bill))
 
-	s.Equal(BillStatusClosed, bill.Status)
+	s.assertClosed(bill)
 	s.NotNil(bill.ClosedAt)
 	s.Len(bill.LineItems, 1)
 }
@@ -293,7 +409,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_CloseAlreadyClosed
 		s.env.SignalWorkflow(SignalCloseBill, secondCloseSignal)
 	}, time.Second*2)
 
-	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency, BillingPeriod{}, false)
 
 	s.True(s.env.IsWorkflowCompleted())
 	s.NoError(s.env.GetWorkflowError())
@@ -303,7 +419,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_CloseAlreadyClosed
 	s.NoError(err)
 	s.NoError(result.Get(&bill))
 
-	s.Equal(BillStatusClosed, bill.Status)
+	s.assertClosed(bill)
 	s.NotNil(bill.ClosedAt)
 	s.Equal(firstCloseAt, *bill.ClosedAt)
 }
@@ -313,7 +429,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_UnsupportedCurrenc
 	customerID := 456
 	currency := money.USD
 
-	jsonData := []byte(`"€100.00"`)
+	jsonData := []byte(`"₿100.00"`)
 	var unsupportedAmount money.Money
 	err := json.Unmarshal(jsonData, &unsupportedAmount)
 	s.Error(err)
@@ -330,7 +446,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_UnsupportedCurrenc
 		s.env.SignalWorkflow(SignalAddLineItem, item)
 	}, time.Second)
 
-	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency, BillingPeriod{}, false)
 
 	s.True(s.env.IsWorkflowCompleted())
 	s.NoError(s.env.GetWorkflowError())
@@ -342,7 +458,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_UnsupportedCurrenc
 
 	s.Equal(billID, bill.ID)
 	s.Equal(customerID, bill.CustomerID)
-	s.Equal(BillStatusClosed, bill.Status)
+	s.assertClosed(bill)
 	s.Len(bill.LineItems, 1)
 	s.Equal("usd-currency-item", bill.LineItems[0].ID)
 	s.Equal(usdAmount, bill.LineItems[0].Amount)
@@ -352,15 +468,13 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_UnsupportedCurrenc
 	origRates := config.Rates
 	defer func() { config.Rates = origRates }()
 
-	customRates := &money.ExchangeRates{
-		USDToGEL: decimal.NewFromFloat(2.7777),
-		GELToUSD: decimal.NewFromFloat(0.3601),
-	}
+	customRates := money.NewRateTable(money.USD)
+	s.NoError(customRates.Add(money.Quote{Currency: money.GEL, Rate: decimal.NewFromFloat(2.7777)}))
 	config.Rates = customRates
 
 	gelAmount, _ := money.NewFromString("200.00", money.GEL)
 
-	_, convErr := gelAmount.ConvertTo(money.Currency("EUR"), config.Rates)
+	_, convErr := gelAmount.ConvertTo(money.EUR, config.Rates, time.Now().UTC())
 	s.Error(convErr)
 }
 
@@ -384,7 +498,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_NegativeAmount() {
 		s.env.SignalWorkflow(SignalAddLineItem, item)
 	}, time.Second)
 
-	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency, BillingPeriod{}, false)
 
 	s.True(s.env.IsWorkflowCompleted())
 	s.NoError(s.env.GetWorkflowError())
@@ -396,7 +510,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_NegativeAmount() {
 
 	s.Equal(billID, bill.ID)
 	s.Equal(customerID, bill.CustomerID)
-	s.Equal(BillStatusClosed, bill.Status)
+	s.assertClosed(bill)
 	s.Len(bill.LineItems, 1)
 	s.Equal(validAmount, bill.LineItems[0].Amount)
 }
@@ -411,8 +525,8 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_MultipleItemsWithD
 	gelAmount1, _ := money.NewFromString("50.00", money.GEL)
 	gelAmount2, _ := money.NewFromString("100.00", money.GEL)
 
-	expectedGelToUsd1, _ := money.NewFromString("18.01", money.USD)
-	expectedGelToUsd2, _ := money.NewFromString("36.01", money.USD)
+	expectedGelToUsd1, _ := money.NewFromString("18.00", money.USD)
+	expectedGelToUsd2, _ := money.NewFromString("36.00", money.USD)
 
 	usdItem1 := LineItem{
 		ID:        "usd-item-1",
@@ -422,7 +536,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_MultipleItemsWithD
 
 	gelItem1 := LineItem{
 		ID:        "gel-item-1",
-		Amount:    gelAmount1,
+		Amount:    s.convert(gelAmount1, currency, time.Now().UTC()),
 		CreatedAt: time.Now().UTC().Add(time.Minute),
 	}
 
@@ -434,7 +548,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_MultipleItemsWithD
 
 	gelItem2 := LineItem{
 		ID:        "gel-item-2",
-		Amount:    gelAmount2,
+		Amount:    s.convert(gelAmount2, currency, time.Now().UTC()),
 		CreatedAt: time.Now().UTC().Add(time.Minute * 3),
 	}
 
@@ -454,7 +568,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_MultipleItemsWithD
 		s.env.SignalWorkflow(SignalAddLineItem, gelItem2)
 	}, time.Second*4)
 
-	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency, BillingPeriod{}, false)
 
 	s.True(s.env.IsWorkflowCompleted())
 	s.NoError(s.env.GetWorkflowError())
@@ -466,10 +580,10 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_MultipleItemsWithD
 
 	s.Equal(billID, bill.ID)
 	s.Equal(customerID, bill.CustomerID)
-	s.Equal(BillStatusClosed, bill.Status)
+	s.assertClosed(bill)
 	s.Len(bill.LineItems, 4)
 
-	expectedTotal, _ := money.NewFromString("84.02", money.USD)
+	expectedTotal, _ := money.NewFromString("84.00", money.USD)
 
 	s.Equal(expectedTotal, bill.Total)
 
@@ -507,7 +621,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_ZeroAmountLineItem
 		s.env.SignalWorkflow(SignalAddLineItem, normalItem)
 	}, time.Second*2)
 
-	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency, BillingPeriod{}, false)
 
 	s.True(s.env.IsWorkflowCompleted())
 	s.NoError(s.env.GetWorkflowError())
@@ -519,7 +633,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_ZeroAmountLineItem
 
 	s.Equal(billID, bill.ID)
 	s.Equal(customerID, bill.CustomerID)
-	s.Equal(BillStatusClosed, bill.Status)
+	s.assertClosed(bill)
 	s.Len(bill.LineItems, 2)
 
 	s.Equal(normalAmount, bill.Total)
@@ -553,7 +667,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_DuplicateLineItemI
 		s.env.SignalWorkflow(SignalAddLineItem, item2)
 	}, time.Second*2)
 
-	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency, BillingPeriod{}, false)
 
 	s.True(s.env.IsWorkflowCompleted())
 	s.NoError(s.env.GetWorkflowError())
@@ -565,7 +679,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_DuplicateLineItemI
 
 	s.Equal(billID, bill.ID)
 	s.Equal(customerID, bill.CustomerID)
-	s.Equal(BillStatusClosed, bill.Status)
+	s.assertClosed(bill)
 	s.Len(bill.LineItems, 2)
 
 	expectedTotal, _ := amount1.Add(amount2)
@@ -639,7 +753,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_HighPrecisionAmoun
 		s.env.SignalWorkflow(SignalAddLineItem, item5)
 	}, time.Second*5)
 
-	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency, BillingPeriod{}, false)
 
 	s.True(s.env.IsWorkflowCompleted())
 	s.NoError(s.env.GetWorkflowError())
@@ -651,7 +765,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_HighPrecisionAmoun
 
 	s.Equal(billID, bill.ID)
 	s.Equal(customerID, bill.CustomerID)
-	s.Equal(BillStatusClosed, bill.Status)
+	s.assertClosed(bill)
 	s.Len(bill.LineItems, 5)
 
 	expectedTotal, _ := money.NewFromString("0.05", currency)
@@ -681,7 +795,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_LargeNumberOfLineI
 		}, delay)
 	}
 
-	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency, BillingPeriod{}, false)
 
 	s.True(s.env.IsWorkflowCompleted())
 	s.NoError(s.env.GetWorkflowError())
@@ -693,7 +807,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_LargeNumberOfLineI
 
 	s.Equal(billID, bill.ID)
 	s.Equal(customerID, bill.CustomerID)
-	s.Equal(BillStatusClosed, bill.Status)
+	s.assertClosed(bill)
 	s.Len(bill.LineItems, numItems)
 
 	expectedTotal, _ := money.NewFromString("100.00", currency)
@@ -737,7 +851,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_AddLineItemAfterCl
 		s.env.SignalWorkflow(SignalAddLineItem, item2)
 	}, time.Second*3)
 
-	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency, BillingPeriod{}, false)
 
 	s.True(s.env.IsWorkflowCompleted())
 	s.NoError(s.env.GetWorkflowError())
@@ -749,7 +863,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_AddLineItemAfterCl
 
 	s.Equal(billID, bill.ID)
 	s.Equal(customerID, bill.CustomerID)
-	s.Equal(BillStatusClosed, bill.Status)
+	s.assertClosed(bill)
 	s.NotNil(bill.ClosedAt)
 	s.Equal(closeTime, *bill.ClosedAt)
 	s.Len(bill.LineItems, 1)
@@ -767,7 +881,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_CurrencyConversion
 	usdAmount, _ := money.NewFromString("10.00", money.USD)
 	gelAmount, _ := money.NewFromString("100.00", money.GEL)
 
-	expectedGelToUsd, _ := money.NewFromString("36.01", money.USD)
+	expectedGelToUsd, _ := money.NewFromString("36.00", money.USD)
 
 	usdItem := LineItem{
 		ID:        "usd-item",
@@ -777,7 +891,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_CurrencyConversion
 
 	gelItem := LineItem{
 		ID:        "gel-item",
-		Amount:    gelAmount,
+		Amount:    s.convert(gelAmount, currency, time.Now().UTC()),
 		CreatedAt: time.Now().UTC().Add(time.Minute),
 	}
 
@@ -789,7 +903,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_CurrencyConversion
 		s.env.SignalWorkflow(SignalAddLineItem, gelItem)
 	}, time.Second*2)
 
-	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency, BillingPeriod{}, false)
 
 	s.True(s.env.IsWorkflowCompleted())
 	s.NoError(s.env.GetWorkflowError())
@@ -801,7 +915,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_CurrencyConversion
 
 	s.Equal(billID, bill.ID)
 	s.Equal(customerID, bill.CustomerID)
-	s.Equal(BillStatusClosed, bill.Status)
+	s.assertClosed(bill)
 	s.Len(bill.LineItems, 2)
 
 	s.Equal("usd-item", bill.LineItems[0].ID)
@@ -809,9 +923,9 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_CurrencyConversion
 	s.Equal("gel-item", bill.LineItems[1].ID)
 	s.Equal(expectedGelToUsd, bill.LineItems[1].Amount)
 
-	expectedTotal, _ := money.NewFromString("46.01", currency)
+	expectedTotal, _ := money.NewFromString("46.00", currency)
 	s.Equal(expectedTotal, bill.Total)
-	s.Equal("$46.01", bill.Total.String())
+	s.Equal("$46.00", bill.Total.String())
 }
 
 func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_CurrencyConversionFailure() {
@@ -837,10 +951,10 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_CurrencyConversion
 	origRates := config.Rates
 	defer func() { config.Rates = origRates }()
 
-	config.Rates = &money.ExchangeRates{
-		USDToGEL: decimal.NewFromInt(0),
-		GELToUSD: decimal.NewFromInt(0),
-	}
+	config.Rates = money.NewRateTable(money.USD)
+
+	_, convErr := gelAmount.ConvertTo(currency, config.Rates, gelItem.CreatedAt)
+	s.Error(convErr)
 
 	s.env.RegisterDelayedCallback(func() {
 		s.env.SignalWorkflow(SignalAddLineItem, usdItem)
@@ -850,7 +964,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_CurrencyConversion
 		s.env.SignalWorkflow(SignalAddLineItem, gelItem)
 	}, time.Second*2)
 
-	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency, BillingPeriod{}, false)
 
 	s.True(s.env.IsWorkflowCompleted())
 	s.NoError(s.env.GetWorkflowError())
@@ -862,16 +976,12 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_CurrencyConversion
 
 	s.Equal(billID, bill.ID)
 	s.Equal(customerID, bill.CustomerID)
-	s.Equal(BillStatusClosed, bill.Status)
-	s.Len(bill.LineItems, 2)
+	s.assertClosed(bill)
+	s.Len(bill.LineItems, 1)
 
 	s.Equal("usd-item", bill.LineItems[0].ID)
 	s.Equal(usdAmount, bill.LineItems[0].Amount)
 
-	s.Equal("gel-item", bill.LineItems[1].ID)
-	zeroAmount, _ := money.NewFromString("0", currency)
-	s.Equal(zeroAmount, bill.LineItems[1].Amount)
-
 	s.Equal(usdAmount, bill.Total)
 	s.Equal("$10.00", bill.Total.String())
 }
@@ -884,7 +994,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_LineItemCurrencyMi
 	usdAmount, _ := money.NewFromString("10.00", money.USD)
 	gelAmount, _ := money.NewFromString("50.00", money.GEL)
 
-	expectedGelToUsd, _ := money.NewFromString("18.01", money.USD)
+	expectedGelToUsd, _ := money.NewFromString("18.00", money.USD)
 
 	usdItem := LineItem{
 		ID:        "usd-item",
@@ -894,7 +1004,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_LineItemCurrencyMi
 
 	gelItem := LineItem{
 		ID:        "gel-item",
-		Amount:    gelAmount,
+		Amount:    s.convert(gelAmount, currency, time.Now().UTC()),
 		CreatedAt: time.Now().UTC().Add(time.Minute),
 	}
 
@@ -906,7 +1016,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_LineItemCurrencyMi
 		s.env.SignalWorkflow(SignalAddLineItem, gelItem)
 	}, time.Second*2)
 
-	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency, BillingPeriod{}, false)
 
 	s.True(s.env.IsWorkflowCompleted())
 	s.NoError(s.env.GetWorkflowError())
@@ -918,7 +1028,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_LineItemCurrencyMi
 
 	s.Equal(billID, bill.ID)
 	s.Equal(customerID, bill.CustomerID)
-	s.Equal(BillStatusClosed, bill.Status)
+	s.assertClosed(bill)
 	s.Len(bill.LineItems, 2)
 
 	s.Equal("usd-item", bill.LineItems[0].ID)
@@ -929,9 +1039,9 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_LineItemCurrencyMi
 	s.Equal(expectedGelToUsd, bill.LineItems[1].Amount)
 	s.Equal(money.USD, bill.LineItems[1].Amount.Currency)
 
-	expectedTotal, _ := money.NewFromString("28.01", currency)
+	expectedTotal, _ := money.NewFromString("28.00", currency)
 	s.Equal(expectedTotal, bill.Total)
-	s.Equal("$28.01", bill.Total.String())
+	s.Equal("$28.00", bill.Total.String())
 }
 
 func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_LineItemCurrencyMismatch_GELBill() {
@@ -946,7 +1056,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_LineItemCurrencyMi
 
 	usdItem := LineItem{
 		ID:        "usd-item",
-		Amount:    usdAmount,
+		Amount:    s.convert(usdAmount, gelCurrency, time.Now().UTC()),
 		CreatedAt: time.Now().UTC(),
 	}
 
@@ -956,7 +1066,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_LineItemCurrencyMi
 		s.env.SignalWorkflow(SignalAddLineItem, usdItem)
 	}, time.Second)
 
-	s.env.ExecuteWorkflow(BillingPeriodWorkflow, gelBillID, customerID, gelCurrency)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, gelBillID, customerID, gelCurrency, BillingPeriod{}, false)
 
 	s.True(s.env.IsWorkflowCompleted())
 	s.NoError(s.env.GetWorkflowError())
@@ -969,7 +1079,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_LineItemCurrencyMi
 	s.Equal(gelBillID, gelBill.ID)
 	s.Equal(customerID, gelBill.CustomerID)
 	s.Equal(gelCurrency, gelBill.Currency)
-	s.Equal(BillStatusClosed, gelBill.Status)
+	s.assertClosed(gelBill)
 	s.Len(gelBill.LineItems, 1)
 
 	s.Equal("usd-item", gelBill.LineItems[0].ID)
@@ -1008,7 +1118,7 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_TotalCalculationOv
 		s.env.SignalWorkflow(SignalAddLineItem, item2)
 	}, time.Second*2)
 
-	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency)
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, billID, customerID, currency, BillingPeriod{}, false)
 
 	s.True(s.env.IsWorkflowCompleted())
 	s.NoError(s.env.GetWorkflowError())
@@ -1020,16 +1130,418 @@ func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_TotalCalculationOv
 
 	s.Equal(billID, bill.ID)
 	s.Equal(customerID, bill.CustomerID)
-	s.Equal(BillStatusClosed, bill.Status)
+	s.assertClosed(bill)
 	s.Len(bill.LineItems, 2)
 
-	expectedDecimal := decimal.NewFromFloat(9999999999999999.99).Add(decimal.NewFromFloat(9999999999999999.99))
+	s.True(decimal.RequireFromString("19999999999999999.98").Equal(bill.Total.Amount()))
+	s.Equal("$19999999999999999.98", bill.Total.String())
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_AcceptsLegacyMoneyPayloads() {
+	legacyItem := json.RawMessage(`{"id":"legacy-item","amount":"$12.50","created_at":"2025-01-01T00:00:00Z"}`)
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(SignalAddLineItem, legacyItem)
+	}, time.Second)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	var bill *Bill
+	result, err := s.env.QueryWorkflow(QueryGetBill)
+	s.NoError(err)
+	s.NoError(result.Get(&bill))
+
+	s.Require().Len(bill.LineItems, 1)
+	s.Equal("legacy-item", bill.LineItems[0].ID)
+	s.Equal("$12.50", bill.Total.String())
+	s.Equal(int64(1250), bill.Total.MinorUnits())
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_RoundsUsageOnlyWhenClosing() {
+	unitPrice, err := money.NewPreciseFromString("0.003", money.USD)
+	s.Require().NoError(err)
+
+	for i := 1; i <= 5; i++ {
+		item := LineItem{
+			ID:        fmt.Sprintf("usage-%d", i),
+			Type:      LineItemTypeUsage,
+			Amount:    money.New(money.ZeroAmount(), money.USD),
+			CreatedAt: time.Now().UTC(),
+			UnitPrice: &unitPrice,
+			Quantity:  1,
+		}
+
+		s.env.RegisterDelayedCallback(func() {
+			s.env.SignalWorkflow(SignalAddLineItem, item)
+		}, time.Second*time.Duration(i))
+	}
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(SignalCloseBill, CloseBillSignal{ClosedAt: time.Now().UTC()})
+	}, time.Second*10)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	var bill *Bill
+	result, err := s.env.QueryWorkflow(QueryGetBill)
+	s.NoError(err)
+	s.NoError(result.Get(&bill))
+
+	s.Len(bill.LineItems, 5)
+	s.Equal("$0.015", bill.Subtotal.String())
+	s.Equal("$0.02", bill.Total.String())
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_UpdateAddLineItemsReturnsUpdatedBill() {
+	charge, _ := money.NewFromString("10.00", money.USD)
+	fee, _ := money.NewFromString("0.25", money.USD)
+
+	lineItems := []LineItem{
+		{ID: "charge", Type: LineItemTypeCharge, Amount: charge, CreatedAt: time.Now().UTC()},
+		{ID: "fee", Type: LineItemTypeFXFee, Amount: fee, CreatedAt: time.Now().UTC(), RelatedItemID: "charge"},
+	}
+
+	var updated *Bill
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(UpdateAddLineItems, "update-1", &testsuite.TestUpdateCallback{
+			OnAccept: func() {},
+			OnReject: func(err error) { s.Fail("update should not be rejected", err) },
+			OnComplete: func(result interface{}, err error) {
+				s.NoError(err)
+				updated = result.(*Bill)
+			},
+		}, AddLineItemsRequest{LineItems: lineItems})
+	}, time.Second)
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(UpdateCloseBill, "update-2", &testsuite.TestUpdateCallback{
+			OnAccept: func() {},
+			OnReject: func(err error) { s.Fail("update should not be rejected", err) },
+			OnComplete: func(result interface{}, err error) {
+				s.NoError(err)
+				s.Equal(BillStatusClosed, result.(*Bill).Status)
+			},
+		}, CloseBillSignal{ClosedAt: time.Now().UTC()})
+	}, time.Second*2)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	s.Require().NotNil(updated)
+	s.Len(updated.LineItems, 2)
+	s.Equal("$10.25", updated.Total.String())
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_UpdateRejectsItemsOnClosedBill() {
+	amount, _ := money.NewFromString("10.00", money.USD)
+	lineItem := LineItem{ID: "late-item", Amount: amount, CreatedAt: time.Now().UTC()}
+
+	var rejection error
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(UpdateCloseBill, "close", &testsuite.TestUpdateCallback{
+			OnAccept:   func() {},
+			OnReject:   func(err error) { s.Fail("update should not be rejected", err) },
+			OnComplete: func(interface{}, error) {},
+		}, CloseBillSignal{ClosedAt: time.Now().UTC()})
+
+		s.env.UpdateWorkflow(UpdateAddLineItems, "add", &testsuite.TestUpdateCallback{
+			OnAccept:   func() { s.Fail("update should be rejected") },
+			OnReject:   func(err error) { rejection = err },
+			OnComplete: func(interface{}, error) {},
+		}, AddLineItemsRequest{LineItems: []LineItem{lineItem}})
+	}, time.Second)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+	s.ErrorContains(rejection, "bill is closed")
+
+	var bill *Bill
+	result, err := s.env.QueryWorkflow(QueryGetBill)
+	s.NoError(err)
+	s.NoError(result.Get(&bill))
+	s.Len(bill.LineItems, 0)
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_UpdateRejectsMixedCurrencyBatch() {
+	usd, _ := money.NewFromString("10.00", money.USD)
+	gel, _ := money.NewFromString("10.00", money.GEL)
 
-	actualDecimal := bill.Total.Amount()
-	s.True(actualDecimal.GreaterThanOrEqual(expectedDecimal.Sub(decimal.NewFromFloat(0.1))),
-		"Total should be approximately equal to expected value")
-	s.True(actualDecimal.LessThanOrEqual(expectedDecimal.Add(decimal.NewFromFloat(0.1))),
-		"Total should be approximately equal to expected value")
+	var rejection error
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(UpdateAddLineItems, "add", &testsuite.TestUpdateCallback{
+			OnAccept:   func() { s.Fail("update should be rejected") },
+			OnReject:   func(err error) { rejection = err },
+			OnComplete: func(interface{}, error) {},
+		}, AddLineItemsRequest{LineItems: []LineItem{{ID: "usd", Amount: usd}, {ID: "gel", Amount: gel}}})
+	}, time.Second)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.NoError(s.env.GetWorkflowError())
+	s.ErrorContains(rejection, "does not match bill currency")
+
+	var bill *Bill
+	result, err := s.env.QueryWorkflow(QueryGetBill)
+	s.NoError(err)
+	s.NoError(result.Get(&bill))
+	s.Len(bill.LineItems, 0)
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_ReplayedIdempotencyKeyAddsNothing() {
+	amount, _ := money.NewFromString("10.00", money.USD)
+
+	request := func(id string) AddLineItemsRequest {
+		return AddLineItemsRequest{
+			IdempotencyKey: "retry-key",
+			Fingerprint:    "10.00|USD",
+			LineItems:      []LineItem{{ID: id, Amount: amount, CreatedAt: time.Now().UTC()}},
+		}
+	}
+
+	var replayed *Bill
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflowNoRejection(UpdateAddLineItems, "first", s.T(), request("original"))
+	}, time.Second)
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(UpdateAddLineItems, "retry", &testsuite.TestUpdateCallback{
+			OnAccept: func() {},
+			OnReject: func(err error) { s.Fail("update should not be rejected", err) },
+			OnComplete: func(result interface{}, err error) {
+				s.NoError(err)
+				replayed = result.(*Bill)
+			},
+		}, request("retried"))
+	}, time.Second*2)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.NoError(s.env.GetWorkflowError())
+	s.Require().NotNil(replayed)
+	s.Require().Len(replayed.LineItems, 1)
+	s.Equal("original", replayed.LineItems[0].ID)
+	s.Equal("$10.00", replayed.Total.String())
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_RejectsIdempotencyKeyForDifferentRequest() {
+	amount, _ := money.NewFromString("10.00", money.USD)
+	lineItems := []LineItem{{ID: "item", Amount: amount, CreatedAt: time.Now().UTC()}}
+
+	var rejection error
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflowNoRejection(UpdateAddLineItems, "first", s.T(),
+			AddLineItemsRequest{IdempotencyKey: "key", Fingerprint: "a", LineItems: lineItems})
+	}, time.Second)
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(UpdateAddLineItems, "second", &testsuite.TestUpdateCallback{
+			OnAccept:   func() { s.Fail("update should be rejected") },
+			OnReject:   func(err error) { rejection = err },
+			OnComplete: func(interface{}, error) {},
+		}, AddLineItemsRequest{IdempotencyKey: "key", Fingerprint: "b", LineItems: lineItems})
+	}, time.Second*2)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.NoError(s.env.GetWorkflowError())
+	s.ErrorContains(rejection, "already used for a different request")
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_VoidLineItemRecomputesTotal() {
+	charge, _ := money.NewFromString("10.00", money.USD)
+	fee, _ := money.NewFromString("0.25", money.USD)
+	other, _ := money.NewFromString("5.00", money.USD)
+
+	lineItems := []LineItem{
+		{ID: "charge", Type: LineItemTypeCharge, Amount: charge, CreatedAt: time.Now().UTC()},
+		{ID: "fee", Type: LineItemTypeFXFee, Amount: fee, CreatedAt: time.Now().UTC(), RelatedItemID: "charge"},
+		{ID: "other", Type: LineItemTypeCharge, Amount: other, CreatedAt: time.Now().UTC()},
+	}
+
+	var voided *Bill
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflowNoRejection(UpdateAddLineItems, "add", s.T(), AddLineItemsRequest{LineItems: lineItems})
+	}, time.Second)
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(UpdateVoidLineItem, "void", &testsuite.TestUpdateCallback{
+			OnAccept: func() {},
+			OnReject: func(err error) { s.Fail("update should not be rejected", err) },
+			OnComplete: func(result interface{}, err error) {
+				s.NoError(err)
+				voided = result.(*Bill)
+			},
+		}, VoidLineItemRequest{LineItemID: "charge", Reason: "duplicate charge", VoidedBy: "support@pave.dev", VoidedAt: time.Now().UTC()})
+	}, time.Second*2)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.NoError(s.env.GetWorkflowError())
+	s.Require().NotNil(voided)
+	s.Len(voided.LineItems, 3)
+	s.Equal("$5.00", voided.Total.String())
+
+	s.Require().NotNil(voided.LineItems[0].Void)
+	s.Equal("duplicate charge", voided.LineItems[0].Void.Reason)
+	s.Equal("support@pave.dev", voided.LineItems[0].Void.VoidedBy)
+	s.NotNil(voided.LineItems[1].Void, "the conversion fee is voided with its charge")
+	s.Nil(voided.LineItems[2].Void)
+
+	var bill *Bill
+	result, err := s.env.QueryWorkflow(QueryGetBill)
+	s.NoError(err)
+	s.NoError(result.Get(&bill))
+	s.Equal("$5.00", bill.Total.String())
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_VoidRejectsUnknownAndVoidedItems() {
+	amount, _ := money.NewFromString("10.00", money.USD)
+	request := VoidLineItemRequest{LineItemID: "item", Reason: "mistake", VoidedBy: "ops", VoidedAt: time.Now().UTC()}
+
+	var rejections []error
+	reject := &testsuite.TestUpdateCallback{
+		OnAccept:   func() { s.Fail("update should be rejected") },
+		OnReject:   func(err error) { rejections = append(rejections, err) },
+		OnComplete: func(interface{}, error) {},
+	}
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(UpdateVoidLineItem, "unknown", reject, request)
+		s.env.UpdateWorkflowNoRejection(UpdateAddLineItems, "add", s.T(),
+			AddLineItemsRequest{LineItems: []LineItem{{ID: "item", Amount: amount}}})
+		s.env.UpdateWorkflowNoRejection(UpdateVoidLineItem, "void", s.T(), request)
+		s.env.UpdateWorkflow(UpdateVoidLineItem, "again", reject, request)
+		s.env.UpdateWorkflow(UpdateVoidLineItem, "no-reason", reject, VoidLineItemRequest{LineItemID: "item"})
+	}, time.Second)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{}, false)
+
+	s.NoError(s.env.GetWorkflowError())
+	s.Len(rejections, 3)
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_ClosesAtConfiguredPeriodEnd() {
+	s.env.SetStartTime(time.Date(2025, 1, 15, 10, 0, 0, 0, time.UTC))
+
+	period := BillingPeriod{Interval: BillingIntervalWeekly, Timezone: "Asia/Tbilisi"}
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, period, false)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	var bill *Bill
+	result, err := s.env.QueryWorkflow(QueryGetBill)
+	s.NoError(err)
+	s.NoError(result.Get(&bill))
+
+	// Monday midnight in Tbilisi (UTC+4)
+	s.Equal(time.Date(2025, 1, 12, 20, 0, 0, 0, time.UTC), bill.PeriodStart)
+	s.Equal(time.Date(2025, 1, 19, 20, 0, 0, 0, time.UTC), bill.PeriodEnd)
+	s.Equal(period, bill.Period)
+	s.Require().NotNil(bill.ClosedAt)
+	s.True(bill.ClosedAt.Equal(bill.PeriodEnd))
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_RejectsInvalidPeriod() {
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 456, money.USD, BillingPeriod{Interval: "HOURLY"}, false)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.ErrorContains(s.env.GetWorkflowError(), "invalid billing period")
+}
+
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_AddsTaxWhenClosing() {
+	config.CustomerJurisdictions[789] = "GE"
+	defer delete(config.CustomerJurisdictions, 789)
+
+	service, _ := money.NewFromString("100.00", money.USD)
+	book, _ := money.NewFromString("20.00", money.USD)
+
+	var open *Bill
+
+	s.env.RegisterDelayedCallback(func() {
+		s.env.UpdateWorkflow(UpdateAddLineItems, "add", &testsuite.TestUpdateCallback{
+			OnAccept: func() {},
+			OnReject: func(err error) { s.Fail("update should not be rejected", err) },
+			OnComplete: func(result interface{}, err error) {
+				s.NoError(err)
+				bill := *result.(*Bill)
+				open = &bill
+			},
+		}, AddLineItemsRequest{LineItems: []LineItem{
+			{ID: "service", Amount: service, CreatedAt: time.Now().UTC()},
+			{ID: "book", Amount: book, CreatedAt: time.Now().UTC(), TaxCode: tax.CodeExempt},
+		}})
+	}, time.Second)
+
+	s.env.ExecuteWorkflow(BillingPeriodWorkflow, "bill-123", 789, money.USD, BillingPeriod{}, false)
+
+	s.True(s.env.IsWorkflowCompleted())
+	s.NoError(s.env.GetWorkflowError())
+
+	s.Require().NotNil(open)
+	s.Empty(open.TaxLines)
+	s.Equal("$120.00", open.Total.String())
+
+	var bill *Bill
+	result, err := s.env.QueryWorkflow(QueryGetBill)
+	s.NoError(err)
+	s.NoError(result.Get(&bill))
+
+	s.Require().Len(bill.TaxLines, 1)
+	s.Equal("VAT", bill.TaxLines[0].Name)
+	s.Equal("$100.00", bill.TaxLines[0].Taxable.String())
+	s.Equal("$18.00", bill.Tax.String())
+	s.Equal("$138.00", bill.Total.String())
+	s.Equal("$120.00", bill.Subtotal.String())
+}
+
+// the histories in testdata were recorded by the original BillingPeriodWorkflow, which took no billing
+// period and closed bills on a plain timer, and must keep replaying on the current code
+func (s *BillingWorkflowTestSuite) Test_BillingPeriodWorkflow_ReplaysBaselineHistories() {
+	for _, file := range []string{
+		"testdata/baseline_closed_by_signal.json",
+		"testdata/baseline_closed_at_period_end.json",
+	} {
+		replayer := worker.NewWorkflowReplayer()
+		replayer.RegisterWorkflow(BillingPeriodWorkflow)
+
+		s.NoError(replayer.ReplayWorkflowHistoryFromJSONFile(nil, file), file)
+	}
+}
+
+func (s *BillingWorkflowTestSuite) Test_Bill_MarshalJSON_UsesMoneyFormat() {
+	total, _ := money.NewFromString("10.00", money.USD)
+	bill := Bill{ID: "bill-123", Status: BillStatusOpen, Currency: money.USD, Tax: money.New(money.ZeroAmount(), money.USD)}
+	s.Require().NoError(bill.recalculate([]LineItem{{ID: "item", Amount: total}}))
+
+	data, err := json.Marshal(bill)
+	s.NoError(err)
+	s.Contains(string(data), `"total":{"amount":"10.00","currency":"USD","minor_units":1000}`)
+
+	bill.SetMoneyFormat(money.JSONFormatString)
+	data, err = json.Marshal(&bill)
+	s.NoError(err)
+	s.Contains(string(data), `"total":"$10.00"`)
+	s.Contains(string(data), `"balance_due":"$10.00"`)
 
-	s.Equal("$20000000000000000.00", bill.Total.String())
+	var decoded Bill
+	s.NoError(json.Unmarshal(data, &decoded))
+	s.Equal(total, decoded.Total)
 }
@@ -0,0 +1,104 @@
+// Command importrates loads exchange rates from an ECB eurofxref XML file or a
+// "date,from,to,rate" CSV file into the JSON rate store read by the bill service
+// through BILLING_RATES_FILE, and prints how the imported rates differ from the stored ones.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sunneydev/pave-billing-api/bills/money"
+)
+
+func main() {
+	var (
+		format    = flag.String("format", "", "input format: ecb or csv (defaults to the file extension)")
+		store     = flag.String("store", os.Getenv("BILLING_RATES_FILE"), "rate store to update")
+		base      = flag.String("base", string(money.USD), "base currency when creating a new rate store")
+		dryRun    = flag.Bool("dry-run", false, "report changes without writing the rate store")
+		overwrite = flag.Bool("overwrite", false, "replace rates for dates already in the rate store")
+	)
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: importrates [flags] <file>\n")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+
+	if flag.NArg() != 1 || *store == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), money.RateFormat(*format), *store, money.Currency(*base), *dryRun, *overwrite); err != nil {
+		fmt.Fprintf(os.Stderr, "importrates: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(path string, format money.RateFormat, store string, base money.Currency, dryRun bool, overwrite bool) error {
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".xml":
+			format = money.RateFormatECB
+		case ".csv":
+			format = money.RateFormatCSV
+		default:
+			return fmt.Errorf("cannot infer format of %s, use -format", path)
+		}
+	}
+
+	table, err := loadStore(store, base)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rates, err := money.ParseRates(format, file)
+	if err != nil {
+		return err
+	}
+
+	changes, err := table.Import(rates, overwrite)
+	if err != nil {
+		return err
+	}
+
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+
+	if dryRun {
+		fmt.Printf("%d rates validated, %s not modified\n", len(changes), store)
+		return nil
+	}
+
+	if err := table.Save(store); err != nil {
+		return err
+	}
+
+	fmt.Printf("%d rates imported into %s\n", len(changes), store)
+
+	return nil
+}
+
+func loadStore(path string, base money.Currency) (*money.RateTable, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if !base.IsValid() {
+			return nil, fmt.Errorf("invalid base currency: %s", base)
+		}
+
+		return money.NewRateTable(base), nil
+	}
+
+	return money.LoadRateTable(path)
+}